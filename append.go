@@ -0,0 +1,176 @@
+package menmos
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultAppendChunkSize is the default amount of data buffered by an Appender before it's written to the blob.
+const DefaultAppendChunkSize = 1024 * 1024
+
+// ErrAppenderClosed is returned when writing to a closed Appender.
+var ErrAppenderClosed = errors.New("appender is closed")
+
+// An AppendOption customizes an Appender.
+type AppendOption func(*appendOptions) error
+
+type appendOptions struct {
+	chunkSize    int
+	fsyncOnClose bool
+}
+
+// WithAppendChunkSize sets the amount of data buffered by an Appender before it's written to the blob.
+func WithAppendChunkSize(size int) AppendOption {
+	return func(o *appendOptions) error {
+		if size <= 0 {
+			return fmt.Errorf("invalid append chunk size: %d", size)
+		}
+		o.chunkSize = size
+		return nil
+	}
+}
+
+// WithFsyncOnClose makes closing an Appender return only once the storage node has flushed the blob to disk.
+func WithFsyncOnClose() AppendOption {
+	return func(o *appendOptions) error {
+		o.fsyncOnClose = true
+		return nil
+	}
+}
+
+// Appender writes data to the end of an existing blob. It implements io.WriteCloser.
+//
+// Written data is buffered and sent in chunks with ranged writes, so it only becomes visible in the blob
+// once a chunk is full, Flush is called or the appender is closed.
+// An Appender isn't safe for concurrent use, and the blob must not be written by anyone else while it is open.
+type Appender struct {
+	ctx    context.Context
+	client *Client
+	blobID string
+
+	options appendOptions
+	buf     []byte
+	size    int64
+	err     error
+	closed  bool
+}
+
+var _ io.WriteCloser = (*Appender)(nil)
+
+// OpenAppender returns an Appender writing to the end of an existing blob,
+// with the same restrictions as WriteAt. The current size of the blob is fetched from its storage node.
+func (c *Client) OpenAppender(ctx context.Context, blobID string, opts ...AppendOption) (*Appender, error) {
+	options := appendOptions{chunkSize: DefaultAppendChunkSize}
+	for _, opt := range opts {
+		if err := opt(&options); err != nil {
+			return nil, err
+		}
+	}
+
+	meta, err := c.checkWritable(ctx, blobID)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := c.blobSize(ctx, blobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.dropChecksum(ctx, blobID, meta); err != nil {
+		return nil, err
+	}
+
+	return &Appender{
+		ctx:     ctx,
+		client:  c,
+		blobID:  blobID,
+		options: options,
+		buf:     make([]byte, 0, options.chunkSize),
+		size:    size,
+	}, nil
+}
+
+// Size returns the size of the blob once all written data is flushed.
+func (a *Appender) Size() int64 {
+	return a.size + int64(len(a.buf))
+}
+
+// Write buffers p, writing full chunks to the end of the blob.
+// After a failed write, the appender stops accepting data and returns the same error.
+func (a *Appender) Write(p []byte) (int, error) {
+	if a.closed {
+		return 0, ErrAppenderClosed
+	}
+	if a.err != nil {
+		return 0, a.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		n := a.options.chunkSize - len(a.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+		a.buf = append(a.buf, p[:n]...)
+		p = p[n:]
+		written += n
+
+		if len(a.buf) == a.options.chunkSize {
+			if err := a.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+// Flush writes the buffered data to the end of the blob.
+func (a *Appender) Flush() error {
+	if a.closed {
+		return ErrAppenderClosed
+	}
+	return a.flush()
+}
+
+func (a *Appender) flush() (err error) {
+	if a.err != nil {
+		return a.err
+	}
+	if len(a.buf) == 0 {
+		return nil
+	}
+
+	ctx, finish := a.client.startOperation(a.ctx, OpAppend)
+	defer finish(&err)
+
+	if err := a.client.writeRange(ctx, a.blobID, a.size, a.buf); err != nil {
+		a.err = err
+		return err
+	}
+
+	a.size += int64(len(a.buf))
+	a.buf = a.buf[:0]
+	return nil
+}
+
+// Close flushes the buffered data and, if requested, fsyncs the blob. Closing an appender twice is a no-op.
+func (a *Appender) Close() error {
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+
+	if err := a.flush(); err != nil {
+		return err
+	}
+
+	if a.options.fsyncOnClose {
+		return a.client.Fsync(a.ctx, a.blobID)
+	}
+	return nil
+}
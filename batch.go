@@ -0,0 +1,133 @@
+package menmos
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/menmos/menmos-go/payload"
+	"github.com/pkg/errors"
+)
+
+// ErrBatchNotProcessed is the error of the blobs a batch operation didn't get to before its context was done.
+var ErrBatchNotProcessed = errors.New("blob not processed")
+
+// BatchOptions controls how the blobs of a batch operation are processed.
+type BatchOptions struct {
+	// Concurrency is the maximum number of blobs processed concurrently.
+	Concurrency int
+
+	// RateLimit is the maximum number of blobs whose processing starts every second. Unlimited if zero.
+	RateLimit float64
+
+	// Progress, if set, is called every time a blob is processed, with the number of processed blobs and the total.
+	// Calls are serialized.
+	Progress func(done int, total int)
+}
+
+// BatchResult is the outcome of a batch operation on a single blob.
+type BatchResult struct {
+	ID  string
+	Err error
+}
+
+// BatchResults are the outcomes of a batch operation, in the order of the requested IDs.
+type BatchResults []BatchResult
+
+// Failed returns the results of the blobs whose operation failed.
+func (r BatchResults) Failed() BatchResults {
+	var failed BatchResults
+	for _, result := range r {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Err returns an error summarizing the failures of the batch, or nil if every operation succeeded.
+func (r BatchResults) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d blobs failed, first failure on '%s': %v", len(failed), len(r), failed[0].ID, failed[0].Err)
+}
+
+// Runs op for every blob ID, recording the outcome of each one instead of stopping at the first failure.
+// The returned error is only set if the context was done before every blob was processed,
+// in which case the blobs that weren't processed fail with ErrBatchNotProcessed.
+func runBatch(ctx context.Context, ids []string, opts BatchOptions, op func(ctx context.Context, blobID string) error) (BatchResults, error) {
+	results := make(BatchResults, len(ids))
+	for i, id := range ids {
+		results[i] = BatchResult{ID: id, Err: ErrBatchNotProcessed}
+	}
+
+	var ticks <-chan time.Time
+	if opts.RateLimit > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.RateLimit))
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	var progressMu sync.Mutex
+	done := 0
+
+	err := runConcurrently(ctx, opts.Concurrency, len(ids), func(ctx context.Context, i int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if ticks != nil {
+			select {
+			case <-ticks:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		results[i].Err = op(ctx, ids[i])
+
+		if opts.Progress != nil {
+			progressMu.Lock()
+			done++
+			opts.Progress(done, len(ids))
+			progressMu.Unlock()
+		}
+		return nil
+	})
+
+	return results, err
+}
+
+// BatchUpdateMeta applies a mutator to the metadata of every blob, saving the metadata it changed.
+// The mutator is called concurrently, on a copy of the metadata of each blob.
+// Blobs whose metadata isn't changed by the mutator aren't updated.
+func (c *Client) BatchUpdateMeta(ctx context.Context, blobIDs []string, mutator func(meta *payload.BlobMeta), opts BatchOptions) (_ BatchResults, err error) {
+	ctx, finish := c.startOperation(ctx, OpBatchUpdateMeta)
+	defer finish(&err)
+
+	return runBatch(ctx, blobIDs, opts, func(ctx context.Context, blobID string) error {
+		meta, err := c.GetMetadataContext(ctx, blobID)
+		if err != nil {
+			return err
+		}
+
+		original, mutated := CopyMeta(meta), CopyMeta(meta)
+		mutator(&mutated)
+		if reflect.DeepEqual(original, mutated) {
+			return nil
+		}
+
+		return c.UpdateMetaContext(ctx, blobID, mutated)
+	})
+}
+
+// BatchDelete deletes every blob.
+func (c *Client) BatchDelete(ctx context.Context, blobIDs []string, opts BatchOptions) (_ BatchResults, err error) {
+	ctx, finish := c.startOperation(ctx, OpBatchDelete)
+	defer finish(&err)
+
+	return runBatch(ctx, blobIDs, opts, c.DeleteContext)
+}
@@ -0,0 +1,122 @@
+package menmos_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/payload"
+)
+
+func Test_BatchUpdateMeta(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		ids = append(ids, server.Put([]byte("data"), payload.NewBlobMeta()))
+	}
+	ids = append(ids, "missing")
+
+	var progress []int
+	results, err := client.BatchUpdateMeta(ctx, ids, func(meta *payload.BlobMeta) {
+		meta.Tags = append(meta.Tags, "retagged")
+	}, menmos.BatchOptions{
+		Concurrency: 3,
+		Progress:    func(done, total int) { progress = append(progress, done) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != len(ids) {
+		t.Fatalf("expected %d results, got %d", len(ids), len(results))
+	}
+	for i, result := range results {
+		if result.ID != ids[i] {
+			t.Errorf("result %d is for '%s', expected '%s'", i, result.ID, ids[i])
+		}
+	}
+
+	failed := results.Failed()
+	if len(failed) != 1 || failed[0].ID != "missing" {
+		t.Errorf("expected only the missing blob to fail, got %v", failed)
+	}
+	if results.Err() == nil {
+		t.Error("expected the batch to report its failure")
+	}
+
+	for _, id := range ids[:5] {
+		blob, _ := server.Blob(id)
+		if len(blob.Meta.Tags) != 1 || blob.Meta.Tags[0] != "retagged" {
+			t.Errorf("blob '%s' wasn't retagged: %v", id, blob.Meta.Tags)
+		}
+	}
+
+	if len(progress) != len(ids) || progress[len(progress)-1] != len(ids) {
+		t.Errorf("unexpected progress %v", progress)
+	}
+}
+
+func Test_BatchUpdateMetaSkipsUnchanged(t *testing.T) {
+	client, server := newTestClient(t)
+	id := server.Put([]byte("data"), payload.NewBlobMeta())
+
+	before := len(server.Requests())
+	results, err := client.BatchUpdateMeta(context.Background(), []string{id}, func(meta *payload.BlobMeta) {}, menmos.BatchOptions{})
+	if err != nil || results.Err() != nil {
+		t.Fatal(err, results.Err())
+	}
+
+	for _, req := range server.Requests()[before:] {
+		if req.Method == "PUT" {
+			t.Errorf("unexpected update request %s %s", req.Method, req.Path)
+		}
+	}
+}
+
+func Test_BatchDelete(t *testing.T) {
+	client, server := newTestClient(t)
+
+	ids := []string{
+		server.Put([]byte("a"), payload.NewBlobMeta()),
+		server.Put([]byte("b"), payload.NewBlobMeta()),
+		server.Put([]byte("c"), payload.NewBlobMeta()),
+	}
+
+	start := time.Now()
+	results, err := client.BatchDelete(context.Background(), ids, menmos.BatchOptions{Concurrency: 3, RateLimit: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := results.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first blob waits for a tick as well.
+	if elapsed := time.Since(start); elapsed < 3*50*time.Millisecond {
+		t.Errorf("expected deletions to be rate limited, took %v", elapsed)
+	}
+
+	for _, id := range ids {
+		if _, ok := server.Blob(id); ok {
+			t.Errorf("blob '%s' wasn't deleted", id)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = client.BatchDelete(ctx, ids, menmos.BatchOptions{})
+	if err == nil {
+		t.Error("expected a cancelled batch to fail")
+	}
+	if failed := results.Failed(); len(failed) != len(ids) {
+		t.Fatalf("expected every blob to fail, got %+v", results)
+	}
+	for _, result := range results {
+		if result.Err != menmos.ErrBatchNotProcessed {
+			t.Errorf("blob '%s': expected ErrBatchNotProcessed, got %v", result.ID, result.Err)
+		}
+	}
+}
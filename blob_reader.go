@@ -1,22 +1,61 @@
 package menmos
 
 import (
+	"context"
 	"io"
+
+	"github.com/pkg/errors"
 )
 
 type rangeReader struct {
+	ctx context.Context
+
 	BlobID string
 	Client *Client
 
 	RangeStart int64
 	RangeEnd   int64
+
+	clamped bool
 }
 
+// Clamps the end of the range to the size of the blob, so reading past its end returns io.EOF.
+func (r *rangeReader) clamp(ctx context.Context) error {
+	size, err := r.Client.blobSize(ctx, r.BlobID)
+	if err != nil {
+		return err
+	}
+
+	if r.RangeEnd > size-1 {
+		r.RangeEnd = size - 1
+	}
+	r.clamped = true
+	return nil
+}
+
+// Read fetches the next chunk of the range from the storage node.
+// The range is only clamped to the blob size once the storage node reports it ends past the blob.
 func (r *rangeReader) Read(buf []byte) (int, error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
 	if r.RangeStart > r.RangeEnd {
 		return 0, io.EOF
 	}
 
+	n, err := r.readChunk(buf)
+	if n == 0 && err == nil {
+		return 0, io.EOF
+	}
+	return n, err
+}
+
+// Reads the next chunk of the range, returning 0 bytes once the end of the blob is reached.
+func (r *rangeReader) readChunk(buf []byte) (n int, err error) {
+	// The span of GetBody ends once the reader is returned, so every chunk gets its own.
+	ctx, finish := r.Client.startOperation(r.ctx, OpReadRange)
+	defer finish(&err)
+
 	requestedDataLength := int64(len(buf))
 	byteRange := (r.RangeEnd - r.RangeStart) + 1
 
@@ -29,19 +68,35 @@ func (r *rangeReader) Read(buf []byte) (int, error) {
 
 	rangeEnd := (r.RangeStart + lengthToRead) - 1
 
-	responseReader, err := r.Client.readRange(r.BlobID, r.RangeStart, rangeEnd)
+	responseReader, servedLength, err := r.Client.readRange(ctx, r.BlobID, r.RangeStart, rangeEnd)
+	if errors.Is(err, errRangeNotSatisfiable) && !r.clamped {
+		if err := r.clamp(ctx); err != nil {
+			return 0, err
+		}
+		if r.RangeStart > r.RangeEnd {
+			return 0, nil
+		}
+		if rangeEnd > r.RangeEnd {
+			rangeEnd, lengthToRead = r.RangeEnd, r.RangeEnd-r.RangeStart+1
+		}
+		responseReader, servedLength, err = r.Client.readRange(ctx, r.BlobID, r.RangeStart, rangeEnd)
+	}
 	if err != nil {
 		return 0, err
 	}
 	defer responseReader.Close()
 
 	readCount, err := io.ReadFull(responseReader, buf[:lengthToRead])
-	if err != nil {
-		return 0, err
+	r.RangeStart += int64(readCount)
+	if (err == io.ErrUnexpectedEOF || err == io.EOF) && servedLength >= 0 && int64(readCount) == servedLength {
+		// The storage node served all of a shorter range: the part of the range within the blob, which ends here.
+		// Any other short read is a truncated response, and is returned as an error.
+		r.RangeEnd = r.RangeStart - 1
+		r.clamped = true
+		return readCount, nil
 	}
 
-	r.RangeStart += int64(readCount)
-	return readCount, nil
+	return readCount, err
 }
 
 func (r *rangeReader) Close() error {
@@ -0,0 +1,106 @@
+package menmos
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/menmos/menmos-go/payload"
+	"github.com/pkg/errors"
+)
+
+// ErrBlobWriterClosed is returned when writing to a closed BlobWriter.
+var ErrBlobWriterClosed = errors.New("blob writer is closed")
+
+// BlobWriter creates a blob from data of unknown size. It implements io.WriteCloser.
+//
+// Menmos needs the size of a blob before its upload starts, so written data is spooled to a temporary file
+// and the blob is only created when the writer is closed. The ID of the new blob is then available from ID.
+// A BlobWriter isn't safe for concurrent use.
+type BlobWriter struct {
+	ctx    context.Context
+	client *Client
+	meta   payload.BlobMeta
+	opts   []UploadOption
+
+	file   *os.File
+	size   uint64
+	id     string
+	closed bool
+}
+
+var _ io.WriteCloser = (*BlobWriter)(nil)
+
+// NewBlobWriter returns a BlobWriter creating a blob with the provided metadata when it's closed.
+func (c *Client) NewBlobWriter(ctx context.Context, meta payload.BlobMeta, opts ...UploadOption) (*BlobWriter, error) {
+	file, err := ioutil.TempFile("", "menmos-writer-*")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create spool file")
+	}
+
+	return &BlobWriter{
+		ctx:    ctx,
+		client: c,
+		meta:   CopyMeta(meta),
+		opts:   opts,
+		file:   file,
+	}, nil
+}
+
+// Write spools p until the writer is closed.
+func (w *BlobWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrBlobWriterClosed
+	}
+
+	n, err := w.file.Write(p)
+	w.size += uint64(n)
+	if err != nil {
+		return n, errors.Wrap(err, "failed to spool blob data")
+	}
+	return n, nil
+}
+
+// Close creates the blob from the spooled data and removes the spool file.
+// Closing a writer twice is a no-op.
+func (w *BlobWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	defer w.removeSpool()
+
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "failed to rewind spool file")
+	}
+
+	var err error
+	w.id, err = w.client.CreateBlobContext(w.ctx, ioutil.NopCloser(w.file), w.meta, w.size, w.opts...)
+	return err
+}
+
+// Abort discards the spooled data without creating a blob.
+func (w *BlobWriter) Abort() {
+	if w.closed {
+		return
+	}
+	w.closed = true
+	w.removeSpool()
+}
+
+// ID returns the ID of the created blob, or an empty string if no blob was created.
+// The ID is set even if Close failed after the blob was created.
+func (w *BlobWriter) ID() string {
+	return w.id
+}
+
+// Size returns the number of bytes written so far.
+func (w *BlobWriter) Size() uint64 {
+	return w.size
+}
+
+func (w *BlobWriter) removeSpool() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
@@ -0,0 +1,200 @@
+package menmos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/menmos/menmos-go/payload"
+	"github.com/pkg/errors"
+)
+
+// SemVer is a semantic version.
+type SemVer struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease string
+}
+
+// ParseSemVer parses a semantic version such as "0.2.1", "v1.0.0-rc.1" or "1.2.3+build".
+func ParseSemVer(version string) (SemVer, error) {
+	raw := version
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+
+	if idx := strings.Index(version, "+"); idx >= 0 {
+		version = version[:idx]
+	}
+
+	var v SemVer
+	if idx := strings.Index(version, "-"); idx >= 0 {
+		v.PreRelease = version[idx+1:]
+		version = version[:idx]
+	}
+
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 {
+		return SemVer{}, fmt.Errorf("invalid semantic version '%s'", raw)
+	}
+
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return SemVer{}, fmt.Errorf("invalid semantic version '%s'", raw)
+		}
+		numbers[i] = n
+	}
+
+	v.Major, v.Minor, v.Patch = numbers[0], numbers[1], numbers[2]
+	return v, nil
+}
+
+func (v SemVer) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.PreRelease != "" {
+		s += "-" + v.PreRelease
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 if the version is lower than, equal to or greater than the other.
+// Pre-releases are lower than their release, and are compared lexically between them.
+func (v SemVer) Compare(other SemVer) int {
+	for _, diff := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if diff < 0 {
+			return -1
+		}
+		if diff > 0 {
+			return 1
+		}
+	}
+
+	switch {
+	case v.PreRelease == other.PreRelease:
+		return 0
+	case v.PreRelease == "":
+		return 1
+	case other.PreRelease == "":
+		return -1
+	case v.PreRelease < other.PreRelease:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// AtLeast returns whether the version is greater than or equal to the other.
+func (v SemVer) AtLeast(other SemVer) bool {
+	return v.Compare(other) >= 0
+}
+
+// A Capability is a server feature the client depends on.
+type Capability string
+
+// Capabilities that aren't supported by every menmos release.
+// A capability is only declared once an operation of the client requires it.
+const (
+	// CapabilityRangedWrites is required by WriteAt and Appender.
+	CapabilityRangedWrites Capability = "ranged writes"
+)
+
+// The first menmos release supporting each capability.
+var capabilityVersions = map[Capability]SemVer{
+	CapabilityRangedWrites: {Major: 0, Minor: 2, Patch: 0},
+}
+
+// ErrServerTooOld is returned when the server doesn't support a capability required by an operation.
+var ErrServerTooOld = errors.New("server too old")
+
+// MinimumVersion returns the first menmos release supporting a capability.
+func (c Capability) MinimumVersion() (SemVer, bool) {
+	v, ok := capabilityVersions[c]
+	return v, ok
+}
+
+// ServerVersion returns the version of the menmos directory.
+// The version is fetched once and cached by the client. Returns an error wrapping ErrServerTooOld if the
+// directory has no version endpoint.
+func (c *Client) ServerVersion(ctx context.Context) (_ SemVer, err error) {
+	ctx, finish := c.startOperation(ctx, OpServerVersion)
+	defer finish(&err)
+
+	c.versionMu.Lock()
+	defer c.versionMu.Unlock()
+
+	if c.serverVersion != nil {
+		return *c.serverVersion, nil
+	}
+
+	req, err := c.makeJSONRequest(ctx, "GET", "/version", nil)
+	if err != nil {
+		return SemVer{}, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return SemVer{}, errors.Wrap(err, "failed to get server version")
+	}
+	defer resp.Body.Close()
+
+	// Releases predating the version endpoint don't support any of the capabilities.
+	if resp.StatusCode == http.StatusNotFound {
+		return SemVer{}, errors.Wrap(ErrServerTooOld, "server has no version endpoint")
+	}
+	if !isStatusSuccess(resp.StatusCode) {
+		bb, _ := ioutil.ReadAll(resp.Body)
+		return SemVer{}, fmt.Errorf("failed to get server version: unexpected status '%s': %s", resp.Status, string(bb))
+	}
+
+	var response payload.VersionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return SemVer{}, errors.Wrap(err, "failed to deserialize server version")
+	}
+
+	version, err := ParseSemVer(response.Version)
+	if err != nil {
+		return SemVer{}, errors.Wrap(err, "failed to parse server version")
+	}
+
+	c.serverVersion = &version
+	return version, nil
+}
+
+// SupportsCapability returns whether the server supports a capability.
+func (c *Client) SupportsCapability(ctx context.Context, capability Capability) (bool, error) {
+	minimum, ok := capability.MinimumVersion()
+	if !ok {
+		return false, fmt.Errorf("unknown capability '%s'", capability)
+	}
+
+	version, err := c.ServerVersion(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return version.AtLeast(minimum), nil
+}
+
+// RequireCapability returns an error wrapping ErrServerTooOld if the server doesn't support a capability.
+func (c *Client) RequireCapability(ctx context.Context, capability Capability) (err error) {
+	ctx, finish := c.startOperation(ctx, OpRequireCapability)
+	defer finish(&err)
+
+	supported, err := c.SupportsCapability(ctx, capability)
+	if err != nil {
+		return err
+	}
+
+	if !supported {
+		minimum, _ := capability.MinimumVersion()
+		version, _ := c.ServerVersion(ctx)
+		return errors.Wrapf(ErrServerTooOld, "%s requires menmos %s or later, server is %s", capability, minimum, version)
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package menmos_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/pkg/errors"
+)
+
+func Test_ParseSemVer(t *testing.T) {
+	type testCase struct {
+		name     string
+		src      string
+		expected menmos.SemVer
+		wantErr  bool
+	}
+
+	cases := []testCase{
+		{"basic", "0.2.1", menmos.SemVer{Major: 0, Minor: 2, Patch: 1}, false},
+		{"v prefix", "v1.10.0", menmos.SemVer{Major: 1, Minor: 10, Patch: 0}, false},
+		{"pre-release", "1.0.0-rc.1", menmos.SemVer{Major: 1, PreRelease: "rc.1"}, false},
+		{"build metadata", "1.2.3+abc", menmos.SemVer{Major: 1, Minor: 2, Patch: 3}, false},
+		{"missing patch", "1.2", menmos.SemVer{}, true},
+		{"not a number", "1.x.3", menmos.SemVer{}, true},
+		{"negative", "1.-1.3", menmos.SemVer{}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := menmos.ParseSemVer(tc.src)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func Test_SemVerCompare(t *testing.T) {
+	ordered := []string{"0.1.0", "0.2.0-alpha", "0.2.0-beta", "0.2.0", "0.2.1", "0.10.0", "1.0.0"}
+	for i := range ordered {
+		for j := range ordered {
+			a, _ := menmos.ParseSemVer(ordered[i])
+			b, _ := menmos.ParseSemVer(ordered[j])
+
+			expected := 0
+			if i < j {
+				expected = -1
+			} else if i > j {
+				expected = 1
+			}
+			if actual := a.Compare(b); actual != expected {
+				t.Errorf("%s vs %s: expected %d, got %d", a, b, expected, actual)
+			}
+		}
+	}
+}
+
+func Test_RequireCapability(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	if err := client.RequireCapability(ctx, menmos.CapabilityRangedWrites); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	server.SetVersion("0.1.2")
+	old, err := menmos.New(server.URL, "admin", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := old.ServerVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.String() != "0.1.2" {
+		t.Errorf("unexpected version %s", version)
+	}
+
+	err = old.RequireCapability(ctx, menmos.CapabilityRangedWrites)
+	if errors.Cause(err) != menmos.ErrServerTooOld {
+		t.Errorf("expected ErrServerTooOld, got %v", err)
+	}
+
+	if _, err := old.SupportsCapability(ctx, menmos.Capability("teleportation")); err == nil {
+		t.Error("expected an unknown capability to be rejected")
+	}
+}
+
+func Test_RequireCapabilityWithoutVersionEndpoint(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	server.Fail("GET", "/version", http.StatusNotFound, 1)
+
+	if _, err := client.ServerVersion(ctx); errors.Cause(err) != menmos.ErrServerTooOld {
+		t.Errorf("expected ErrServerTooOld, got %v", err)
+	}
+
+	server.Fail("GET", "/version", http.StatusNotFound, 1)
+
+	err := client.RequireCapability(ctx, menmos.CapabilityRangedWrites)
+	if errors.Cause(err) != menmos.ErrServerTooOld {
+		t.Errorf("expected ErrServerTooOld, got %v", err)
+	}
+}
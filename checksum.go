@@ -0,0 +1,244 @@
+package menmos
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/menmos/menmos-go/payload"
+	"github.com/pkg/errors"
+)
+
+// ChecksumField is the reserved metadata field storing the checksum of a blob body, as "<algorithm>:<hex digest>".
+// For encoded blobs, the checksum is the one of the decoded body.
+const ChecksumField = "_checksum"
+
+// ChecksumSHA256 is the checksum algorithm used for new blobs.
+const ChecksumSHA256 = "sha256"
+
+var checksumAlgorithms = map[string]func() hash.Hash{
+	ChecksumSHA256: sha256.New,
+}
+
+// ErrChecksumMismatch is returned when the content of a blob doesn't match its recorded checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ErrNoChecksum is returned when verifying a blob that has no recorded checksum.
+var ErrNoChecksum = errors.New("blob has no checksum")
+
+// WithChecksums makes the client record the SHA-256 checksum of uploaded bodies in the ChecksumField field,
+// and verify the checksum of whole bodies returned by GetBody.
+//
+// Verifying a download requires fetching the blob metadata first, which costs an extra request.
+func WithChecksums() Option {
+	return func(o *clientOptions) error {
+		o.checksums = true
+		return nil
+	}
+}
+
+func formatChecksum(algorithm string, h hash.Hash) string {
+	return fmt.Sprintf("%s:%s", algorithm, hex.EncodeToString(h.Sum(nil)))
+}
+
+// Splits a checksum field value into its algorithm and hex digest.
+func parseChecksum(checksum string) (func() hash.Hash, string, error) {
+	idx := strings.Index(checksum, ":")
+	if idx < 0 {
+		return nil, "", fmt.Errorf("malformed checksum '%s'", checksum)
+	}
+
+	newHash, ok := checksumAlgorithms[checksum[:idx]]
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported checksum algorithm '%s'", checksum[:idx])
+	}
+
+	return newHash, checksum[idx+1:], nil
+}
+
+func readerChecksum(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return formatChecksum(ChecksumSHA256, h), nil
+}
+
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	return readerChecksum(file)
+}
+
+// Computes the checksum of a seekable body without consuming it.
+// Returns false if the body can't be rewound, in which case it must be hashed while streaming.
+func seekableChecksum(body io.Reader) (string, bool, error) {
+	seeker, ok := body.(io.Seeker)
+	if !ok {
+		return "", false, nil
+	}
+
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		// Not all seekers support seeking (e.g. pipes opened as files).
+		return "", false, nil
+	}
+
+	checksum, err := readerChecksum(body)
+	if err != nil {
+		return "", true, err
+	}
+
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		return "", true, err
+	}
+
+	return checksum, true, nil
+}
+
+// hashingReader hashes a body as it is streamed.
+type hashingReader struct {
+	io.ReadCloser
+	hash hash.Hash
+}
+
+func newHashingReader(body io.ReadCloser) *hashingReader {
+	return &hashingReader{ReadCloser: body, hash: sha256.New()}
+}
+
+func (r *hashingReader) Read(buf []byte) (int, error) {
+	n, err := r.ReadCloser.Read(buf)
+	r.hash.Write(buf[:n])
+	return n, err
+}
+
+func (r *hashingReader) checksum() string {
+	return formatChecksum(ChecksumSHA256, r.hash)
+}
+
+// verifyingReader hashes a body as it is read, and fails at EOF if it doesn't match the expected digest.
+type verifyingReader struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected string
+	blobID   string
+}
+
+func newVerifyingReader(blobID string, body io.ReadCloser, checksum string) (*verifyingReader, error) {
+	newHash, expected, err := parseChecksum(checksum)
+	if err != nil {
+		return nil, err
+	}
+	return &verifyingReader{ReadCloser: body, hash: newHash(), expected: expected, blobID: blobID}, nil
+}
+
+func (r *verifyingReader) Read(buf []byte) (int, error) {
+	n, err := r.ReadCloser.Read(buf)
+	r.hash.Write(buf[:n])
+
+	if err == io.EOF {
+		if actual := hex.EncodeToString(r.hash.Sum(nil)); actual != r.expected {
+			return n, errors.Wrapf(ErrChecksumMismatch, "blob '%s': expected %s, got %s", r.blobID, r.expected, actual)
+		}
+	}
+
+	return n, err
+}
+
+// Prepares the body and metadata of an upload for checksumming.
+// Returns the body to send, the metadata to send, and the hashing reader if the checksum must be recorded after the upload.
+func (c *Client) prepareChecksum(body io.ReadCloser, meta payload.BlobMeta) (io.ReadCloser, payload.BlobMeta, *hashingReader, error) {
+	if !c.checksums {
+		return body, meta, nil, nil
+	}
+
+	meta = CopyMeta(meta)
+
+	if body == nil {
+		checksum, _ := readerChecksum(strings.NewReader(""))
+		meta.Fields[ChecksumField] = checksum
+		return body, meta, nil, nil
+	}
+
+	checksum, ok, err := seekableChecksum(body)
+	if err != nil {
+		return nil, meta, nil, errors.Wrap(err, "failed to compute checksum")
+	}
+
+	if ok {
+		meta.Fields[ChecksumField] = checksum
+		return body, meta, nil, nil
+	}
+
+	// The metadata is sent before the body, the checksum will be recorded once the body is sent.
+	delete(meta.Fields, ChecksumField)
+	hasher := newHashingReader(body)
+	return hasher, meta, hasher, nil
+}
+
+// Wraps a whole blob body in a verifying reader if checksums are enabled and the blob has a checksum.
+func (c *Client) verifyBody(blobID string, body io.ReadCloser, meta payload.BlobMeta) (io.ReadCloser, error) {
+	checksum, ok := meta.Fields[ChecksumField]
+	if !c.checksums || !ok {
+		return body, nil
+	}
+
+	verifier, err := newVerifyingReader(blobID, body, checksum)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	return verifier, nil
+}
+
+// Verify downloads a blob and checks its content against its recorded checksum.
+// Returns ErrNoChecksum if the blob has no checksum, and an error wrapping ErrChecksumMismatch if it doesn't match.
+func (c *Client) Verify(ctx context.Context, blobID string) (err error) {
+	ctx, finish := c.startOperation(ctx, OpVerify)
+	defer finish(&err)
+
+	meta, err := c.GetMetadataContext(ctx, blobID)
+	if err != nil {
+		return err
+	}
+
+	checksum, ok := meta.Fields[ChecksumField]
+	if !ok {
+		return errors.Wrapf(ErrNoChecksum, "blob '%s'", blobID)
+	}
+
+	body, err := c.getBody(ctx, blobID)
+	if err != nil {
+		return err
+	}
+
+	if _, encoded := meta.Fields[CodecField]; encoded {
+		codec, err := c.blobCodec(blobID, meta)
+		if err != nil {
+			body.Close()
+			return err
+		}
+		if body, err = decodeBody(codec, blobID, body, nil); err != nil {
+			return err
+		}
+	}
+	defer body.Close()
+
+	verifier, err := newVerifyingReader(blobID, body, checksum)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(ioutil.Discard, verifier)
+	return err
+}
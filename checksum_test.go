@@ -0,0 +1,104 @@
+package menmos_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/internal/menmostest"
+	"github.com/menmos/menmos-go/payload"
+	"github.com/pkg/errors"
+)
+
+// Hides the io.Seeker implementation of a reader, forcing the checksum to be computed while streaming.
+type streamOnly struct {
+	r *strings.Reader
+}
+
+func (s streamOnly) Read(buf []byte) (int, error) { return s.r.Read(buf) }
+func (s streamOnly) Close() error                 { return nil }
+
+func Test_Checksums(t *testing.T) {
+	server := menmostest.NewServer()
+	defer server.Close()
+
+	client, err := menmos.New(server.URL, "admin", "password", menmos.WithChecksums())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	const helloChecksum = "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	seekableID, err := client.CreateBlob(ioutil.NopCloser(bytes.NewReader([]byte("hello"))), payload.NewBlobMeta(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streamedID, err := client.CreateBlob(streamOnly{strings.NewReader("hello")}, payload.NewBlobMeta(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{seekableID, streamedID} {
+		blob, _ := server.Blob(id)
+		if blob.Meta.Fields[menmos.ChecksumField] != helloChecksum {
+			t.Errorf("%s: unexpected checksum %q", id, blob.Meta.Fields[menmos.ChecksumField])
+		}
+
+		if err := client.Verify(ctx, id); err != nil {
+			t.Errorf("%s: %v", id, err)
+		}
+	}
+
+	server.SetData(seekableID, []byte("jello"))
+
+	if err := client.Verify(ctx, seekableID); !errors.Is(err, menmos.ErrChecksumMismatch) {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+
+	body, err := client.GetBody(seekableID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	if _, err := ioutil.ReadAll(body); !errors.Is(err, menmos.ErrChecksumMismatch) {
+		t.Errorf("expected the download to fail with a checksum mismatch, got %v", err)
+	}
+
+	unchecked := server.Put([]byte("unchecked"), payload.NewBlobMeta())
+	if err := client.Verify(ctx, unchecked); !errors.Is(err, menmos.ErrNoChecksum) {
+		t.Errorf("expected ErrNoChecksum, got %v", err)
+	}
+}
+
+func Test_CreateBlobReturnsIDWhenChecksumRecordFails(t *testing.T) {
+	server := menmostest.NewServer()
+	defer server.Close()
+
+	client, err := menmos.New(server.URL, "admin", "password", menmos.WithChecksums(), menmos.WithMaxRetryCount(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The metadata is first sent with the upload, the checksum of a stream is recorded by a second update.
+	server.Fail(http.MethodPut, "/metadata", http.StatusInternalServerError, 1)
+
+	id, err := client.CreateBlob(ioutil.NopCloser(strings.NewReader("hello")), payload.NewBlobMeta(), 5)
+	if err == nil {
+		t.Fatal("expected the checksum update to fail")
+	}
+	if id == "" {
+		t.Fatal("expected the ID of the created blob along with the error")
+	}
+
+	blob, ok := server.Blob(id)
+	if !ok || string(blob.Data) != "hello" {
+		t.Errorf("expected the returned ID to identify the created blob")
+	}
+}
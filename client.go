@@ -2,6 +2,7 @@ package menmos
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -10,7 +11,9 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/menmos/menmos-go/config"
 	"github.com/menmos/menmos-go/payload"
@@ -24,46 +27,134 @@ type Client struct {
 	httpClient    *http.Client
 	host          string
 	token         string
+	userAgent     string
 	maxRetryCount uint32
+	checksums     bool
+	compression   Codec
+	codecs        map[string]Codec
+	logger        Logger
+	metrics       Metrics
+	tracer        Tracer
+	directoryHost string
+
+	dedupLocks keyedMutex
+
+	versionMu     sync.Mutex
+	serverVersion *SemVer
 }
 
-func New(host string, username string, password string) (*Client, error) {
-	var err error
-	// Block out redirections.
-	// We need to handle those ourselves.
-	customClient := http.Client{
-		CheckRedirect: func(redirRequest *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
+func newClient(host string, opts []Option) (*Client, error) {
+	options := defaultClientOptions()
+	for _, opt := range opts {
+		if err := opt(&options); err != nil {
+			return nil, errors.Wrap(err, "invalid client option")
+		}
+	}
+
+	httpClient, err := options.httpClient()
+	if err != nil {
+		return nil, err
 	}
 
-	client := &Client{
-		httpClient:    &customClient,
+	var directoryHost string
+	if parsed, err := url.Parse(host); err == nil {
+		directoryHost = parsed.Host
+	}
+
+	return &Client{
+		httpClient:    httpClient,
 		host:          strings.TrimSuffix(host, "/"),
 		token:         "",
-		maxRetryCount: 40, // TODO: Make configurable.
+		userAgent:     options.userAgent(),
+		maxRetryCount: options.maxRetryCount,
+		checksums:     options.checksums,
+		compression:   options.compression,
+		codecs:        options.codecs,
+		logger:        options.logger,
+		metrics:       options.metrics,
+		tracer:        options.tracer,
+		directoryHost: directoryHost,
+	}, nil
+}
+
+// New initializes a new menmos client, authenticating with the provided username and password.
+func New(host string, username string, password string, opts ...Option) (*Client, error) {
+	client, err := newClient(host, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	client.token, err = client.authenticate(context.Background(), username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// NewWithToken initializes a new menmos client from a pre-issued token.
+// No authentication request is made.
+func NewWithToken(host string, token string, opts ...Option) (*Client, error) {
+	if token == "" {
+		return nil, errors.New("token cannot be empty")
 	}
 
-	client.token, err = client.authenticate(username, password)
+	client, err := newClient(host, opts)
 	if err != nil {
 		return nil, err
 	}
+	client.token = token
 
 	return client, nil
 }
 
 // NewFromProfile initializes a new menmos client from its profile name.
-func NewFromProfile(profileName string) (*Client, error) {
+// If the profile name is empty, the default profile of the configuration is used.
+// Options passed explicitly take precedence over the settings of the profile.
+func NewFromProfile(profileName string, opts ...Option) (*Client, error) {
 	profile, err := config.LoadProfileFromDefaultConfig(profileName)
 	if err != nil {
 		return nil, err
 	}
-	return New(profile.Host, profile.Username, profile.Password)
+
+	if profileName == "" {
+		return newFromProfile("default profile", profile, opts)
+	}
+	return newFromProfile(fmt.Sprintf("profile '%s'", profileName), profile, opts)
+}
+
+// NewFromProfileConfig initializes a new menmos client from an already-loaded profile.
+func NewFromProfileConfig(profile *config.Profile, opts ...Option) (*Client, error) {
+	return newFromProfile("profile", profile, opts)
+}
+
+// Initializes a client from a profile, wrapping profile errors with a description of the profile.
+func newFromProfile(description string, profile *config.Profile, opts []Option) (*Client, error) {
+	profileOpts, err := profileOptions(profile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", description)
+	}
+	opts = append(profileOpts, opts...)
+
+	if profile.UsesToken() {
+		token, err := profile.ResolveToken()
+		if err != nil {
+			return nil, errors.Wrap(err, description)
+		}
+		return NewWithToken(profile.Host, token, opts...)
+	}
+
+	password, err := profile.ResolvePassword()
+	if err != nil {
+		return nil, errors.Wrap(err, description)
+	}
+
+	return New(profile.Host, profile.Username, password, opts...)
 }
 
 // low-level wrapper function to create an authenticated request to menmos.
-func (c *Client) makeRequest(method string, path string, data io.Reader) (*http.Request, error) {
-	request, err := http.NewRequest(method, c.host+path, data)
+func (c *Client) makeRequest(ctx context.Context, method string, path string, data io.Reader) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, method, c.host+path, data)
 	if err != nil {
 		return nil, errors.Wrapf(err, "%s %s - failed to create request", method, path)
 	}
@@ -72,13 +163,19 @@ func (c *Client) makeRequest(method string, path string, data io.Reader) (*http.
 		request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.token))
 	}
 
-	request.Header.Add("User-Agent", fmt.Sprintf("%s/%s", userAgent, Version))
+	request.Header.Add("User-Agent", c.userAgent)
+
+	keyvals := []interface{}{"method", method, "path", path}
+	if blobID := blobIDFromPath(path); blobID != "" {
+		keyvals = append(keyvals, "blob_id", blobID)
+	}
+	c.logger.Debug("preparing menmos request", keyvals...)
 
 	return request, nil
 }
 
 // Wrapper function to create a request that sends a JSON payload.
-func (c *Client) makeJSONRequest(method string, path string, data interface{}) (*http.Request, error) {
+func (c *Client) makeJSONRequest(ctx context.Context, method string, path string, data interface{}) (*http.Request, error) {
 	var dataReader io.Reader = nil
 	if data != nil {
 		bodyBytes, err := json.Marshal(&data)
@@ -88,7 +185,7 @@ func (c *Client) makeJSONRequest(method string, path string, data interface{}) (
 		dataReader = bytes.NewReader(bodyBytes)
 	}
 
-	req, err := c.makeRequest(method, path, dataReader)
+	req, err := c.makeRequest(ctx, method, path, dataReader)
 	if err != nil {
 		return nil, err
 	}
@@ -102,16 +199,18 @@ func (c *Client) makeJSONRequest(method string, path string, data interface{}) (
 
 // Performs a request and returns the redirect location.
 func (c *Client) doWithRedirect(request *http.Request) (*url.URL, error) {
-	resp, err := c.httpClient.Do(request)
+	resp, err := c.do(request)
 	if err != nil {
 		return nil, errors.Wrap(err, fmt.Sprintf("%s %s - failed to perform redirect request", request.Method, request.URL))
 	}
+	defer resp.Body.Close()
 
 	if isTemporaryRedirect(resp.StatusCode) {
 		redirectLocation, err := resp.Location()
 		if err != nil {
 			return nil, errors.Wrap(err, fmt.Sprintf("%s %s - failed to get redirect location", request.Method, request.URL))
 		}
+		c.logger.Debug("menmos redirect", append(requestKeyvals(request), "location", redactURL(redirectLocation), "storage_node", redirectLocation.Host)...)
 		return redirectLocation, nil
 	}
 
@@ -119,8 +218,18 @@ func (c *Client) doWithRedirect(request *http.Request) (*url.URL, error) {
 
 }
 
+// Returns the body of a response, or an error if its status isn't successful.
+func successBody(req *http.Request, resp *http.Response) (io.ReadCloser, error) {
+	if !isStatusSuccess(resp.StatusCode) {
+		defer resp.Body.Close()
+		bb, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s %s - unexpected status '%s': %s", req.Method, req.URL, resp.Status, string(bb))
+	}
+	return resp.Body, nil
+}
+
 func (c *Client) doJSONRequest(req *http.Request, response interface{}) error {
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return errors.Wrapf(err, "%s %s - request failed", req.Method, req.URL)
 	}
@@ -128,21 +237,26 @@ func (c *Client) doJSONRequest(req *http.Request, response interface{}) error {
 
 	if !isStatusSuccess(resp.StatusCode) {
 		bb, _ := ioutil.ReadAll(resp.Body)
+		c.logger.Warn("menmos request returned an error", append(requestKeyvals(req), "status", resp.StatusCode, "body", string(bb))...)
 		return fmt.Errorf("%s %s - unexpected status '%s': %s", req.Method, req.URL, resp.Status, string(bb))
 	}
 
 	decoder := json.NewDecoder(resp.Body)
 	if err := decoder.Decode(&response); err != nil {
+		c.logger.Warn("failed to deserialize menmos response", append(requestKeyvals(req), "status", resp.StatusCode, "error", err)...)
 		return errors.Wrapf(err, "%s %s - failed to deserialize response", req.Method, req.URL)
 	}
 
 	return nil
 }
 
-func (c *Client) authenticate(username string, password string) (string, error) {
+func (c *Client) authenticate(ctx context.Context, username string, password string) (token string, err error) {
+	ctx, finish := c.startOperation(ctx, OpAuthenticate)
+	defer finish(&err)
+
 	var response payload.LoginResponse
 
-	request, err := c.makeJSONRequest("POST", "/auth/login", &payload.LoginRequest{Username: username, Password: password})
+	request, err := c.makeJSONRequest(ctx, "POST", "/auth/login", &payload.LoginRequest{Username: username, Password: password})
 	if err != nil {
 		return "", err
 	}
@@ -154,30 +268,115 @@ func (c *Client) authenticate(username string, password string) (string, error)
 	return response.Token, nil
 }
 
-func (c *Client) readRange(blobID string, start int64, end int64) (io.ReadCloser, error) {
+// RegisterUser creates a new user on the cluster and returns a token authenticating as that user.
+// Registering users requires the client to be authenticated as an administrator.
+func (c *Client) RegisterUser(ctx context.Context, username string, password string) (token string, err error) {
+	ctx, finish := c.startOperation(ctx, OpRegisterUser)
+	defer finish(&err)
+
+	if username == "" || password == "" {
+		return "", errors.New("register user: username and password cannot be empty")
+	}
+
+	request, err := c.makeJSONRequest(ctx, "POST", "/auth/register", &payload.RegisterRequest{Username: username, Password: password})
+	if err != nil {
+		return "", err
+	}
+
+	var response payload.RegisterResponse
+	if err := c.doJSONRequest(request, &response); err != nil {
+		return "", errors.Wrapf(err, "failed to register user '%s'", username)
+	}
+
+	return response.Token, nil
+}
+
+// Returned by readRange when the range starts past the end of the blob.
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// Reads a range of a blob from its storage node.
+// Also returns the length of the range the storage node served, or -1 if it isn't known.
+func (c *Client) readRange(ctx context.Context, blobID string, start int64, end int64) (io.ReadCloser, int64, error) {
 	if start > end {
-		return nil, fmt.Errorf("invalid range for read request: %d-%d", start, end)
+		return nil, -1, fmt.Errorf("invalid range for read request: %d-%d", start, end)
 	}
 
-	req, err := c.makeJSONRequest("GET", fmt.Sprintf("/blob/%s", blobID), nil)
+	req, err := c.makeJSONRequest(ctx, "GET", fmt.Sprintf("/blob/%s", blobID), nil)
 	if err != nil {
-		return nil, err
+		return nil, -1, err
 	}
 
 	redirectLocation, err := c.doWithRedirect(req)
 	if err != nil {
-		return nil, err
+		return nil, -1, err
 	}
 
 	req.URL = redirectLocation
 	req.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", start, end))
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return nil, errors.Wrap(err, "read request failed")
+		return nil, -1, errors.Wrap(err, "read request failed")
 	}
 
-	return resp.Body, nil
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		resp.Body.Close()
+		return nil, -1, errors.Wrapf(errRangeNotSatisfiable, "%s %s", req.Method, req.URL)
+	}
+
+	servedLength := int64(-1)
+	if resp.StatusCode == http.StatusPartialContent {
+		servedLength = resp.ContentLength
+	}
+
+	body, err := c.countDownload(successBody(req, resp))
+	return body, servedLength, err
+}
+
+// Sends a HEAD request for a blob to its storage node.
+// Returns the response headers and the location of the storage node.
+func (c *Client) headBlob(ctx context.Context, blobID string) (http.Header, *url.URL, error) {
+	req, err := c.makeRequest(ctx, "HEAD", fmt.Sprintf("/blob/%s", blobID), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	redirectLocation, err := c.doWithRedirect(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.URL = redirectLocation
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "%s %s - request failed", req.Method, req.URL)
+	}
+	defer resp.Body.Close()
+
+	if !isStatusSuccess(resp.StatusCode) {
+		return nil, nil, fmt.Errorf("%s %s - unexpected status '%s'", req.Method, req.URL, resp.Status)
+	}
+
+	return resp.Header, redirectLocation, nil
+}
+
+// Parses the size of a blob from the headers of a HEAD response.
+func sizeFromHeader(blobID string, header http.Header) (int64, error) {
+	size, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size for blob '%s': '%s'", blobID, header.Get("Content-Length"))
+	}
+	return size, nil
+}
+
+// Returns the size of a blob, as reported by its storage node.
+func (c *Client) blobSize(ctx context.Context, blobID string) (int64, error) {
+	header, _, err := c.headBlob(ctx, blobID)
+	if err != nil {
+		return 0, err
+	}
+	return sizeFromHeader(blobID, header)
 }
 
 func (c *Client) setMultipartRequestBody(payload io.ReadCloser, req *http.Request) error {
@@ -212,8 +411,31 @@ func (c *Client) setMultipartRequestBody(payload io.ReadCloser, req *http.Reques
 	return nil
 }
 
-func (c *Client) pushInternal(path string, body io.ReadCloser, meta payload.BlobMeta, size uint64) (string, error) {
-	req, err := c.makeRequest("POST", path, nil)
+// Uploads a blob, creating it if blobID is empty.
+// Once the blob is stored, its ID is returned even if a follow-up step fails.
+func (c *Client) pushInternal(ctx context.Context, blobID string, body io.ReadCloser, meta payload.BlobMeta, size uint64, opts uploadOptions) (string, error) {
+	path := "/blob"
+	if blobID != "" {
+		path = fmt.Sprintf("/blob/%s", blobID)
+	}
+
+	body, meta, hasher, err := c.prepareChecksum(body, meta)
+	if err != nil {
+		return "", err
+	}
+
+	body, meta, size, err = c.compressBody(body, meta, size)
+	if err != nil {
+		return "", err
+	}
+
+	if hasher != nil && c.compression != nil && body != nil {
+		// The original body was entirely read while compressing, its checksum can be sent with the upload.
+		meta.Fields[ChecksumField] = hasher.checksum()
+		hasher = nil
+	}
+
+	req, err := c.makeRequest(ctx, "POST", path, nil)
 	if err != nil {
 		return "", err
 	}
@@ -230,7 +452,9 @@ func (c *Client) pushInternal(path string, body io.ReadCloser, meta payload.Blob
 		return "", err
 	}
 
-	req.Body = body
+	if body != nil {
+		req.Body = &countingReader{ReadCloser: body, add: c.metrics.AddBytesUploaded}
+	}
 
 	req.URL = redirectLocation
 
@@ -240,14 +464,39 @@ func (c *Client) pushInternal(path string, body io.ReadCloser, meta payload.Blob
 		return "", err
 	}
 
-	return response.ID, nil
+	if blobID == "" {
+		blobID = response.ID
+	}
+
+	if hasher != nil {
+		meta.Fields[ChecksumField] = hasher.checksum()
+		if err := c.UpdateMetaContext(ctx, blobID, meta); err != nil {
+			return blobID, errors.Wrapf(err, "failed to record checksum of blob '%s'", blobID)
+		}
+	}
+
+	if opts.fsync {
+		if err := c.Fsync(ctx, blobID); err != nil {
+			return blobID, errors.Wrapf(err, "failed to fsync blob '%s'", blobID)
+		}
+	}
+
+	return blobID, nil
 }
 
 // IsHealthy returns whether the menmos cluster is healthy.
 func (c *Client) IsHealthy() (bool, error) {
+	return c.IsHealthyContext(context.Background())
+}
+
+// IsHealthyContext is like IsHealthy but uses the provided context.
+func (c *Client) IsHealthyContext(ctx context.Context) (healthy bool, err error) {
+	ctx, finish := c.startOperation(ctx, OpHealth)
+	defer finish(&err)
+
 	var response payload.MessageResponse
 
-	req, err := c.makeRequest("GET", "/health", nil)
+	req, err := c.makeRequest(ctx, "GET", "/health", nil)
 	if err != nil {
 		return false, err
 	}
@@ -261,9 +510,17 @@ func (c *Client) IsHealthy() (bool, error) {
 
 // Query executes a query on the menmos cluster.
 func (c *Client) Query(query *payload.Query) (*payload.QueryResponse, error) {
+	return c.QueryContext(context.Background(), query)
+}
+
+// QueryContext is like Query but uses the provided context.
+func (c *Client) QueryContext(ctx context.Context, query *payload.Query) (_ *payload.QueryResponse, err error) {
+	ctx, finish := c.startOperation(ctx, OpQuery)
+	defer finish(&err)
+
 	var response payload.QueryResponse
 
-	request, err := c.makeJSONRequest("POST", "/query", query)
+	request, err := c.makeJSONRequest(ctx, "POST", "/query", query)
 	if err != nil {
 		return nil, err
 	}
@@ -278,11 +535,59 @@ func (c *Client) Query(query *payload.Query) (*payload.QueryResponse, error) {
 // Get returns the body of the specified blob.
 // If `readRange` is non-nil, Get will return that section of the blob.
 func (c *Client) GetBody(blobID string, readRange *Range) (io.ReadCloser, error) {
-	if readRange != nil {
-		return &rangeReader{BlobID: blobID, Client: c, RangeStart: readRange.Start, RangeEnd: readRange.End}, nil
+	return c.GetBodyContext(context.Background(), blobID, readRange)
+}
+
+// GetBodyContext is like GetBody but uses the provided context.
+// The operation span and latency end when the body is returned, before it is read.
+//
+// Unless the client is created WithoutDecompression and without checksums, the blob metadata is fetched first.
+func (c *Client) GetBodyContext(ctx context.Context, blobID string, readRange *Range) (_ io.ReadCloser, err error) {
+	ctx, finish := c.startOperation(ctx, OpGetBody)
+	defer finish(&err)
+
+	if !c.checksums && len(c.codecs) == 0 {
+		if readRange != nil {
+			return &rangeReader{ctx: ctx, BlobID: blobID, Client: c, RangeStart: readRange.Start, RangeEnd: readRange.End}, nil
+		}
+		return c.getBody(ctx, blobID)
+	}
+
+	meta, err := c.GetMetadataContext(ctx, blobID)
+	if err != nil {
+		return nil, err
+	}
+
+	codec, err := c.blobCodec(blobID, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	if readRange != nil && codec == nil {
+		return &rangeReader{ctx: ctx, BlobID: blobID, Client: c, RangeStart: readRange.Start, RangeEnd: readRange.End}, nil
+	}
+
+	body, err := c.getBody(ctx, blobID)
+	if err != nil {
+		return nil, err
 	}
 
-	req, err := c.makeJSONRequest("GET", fmt.Sprintf("/blob/%s", blobID), nil)
+	if codec != nil {
+		if body, err = decodeBody(codec, blobID, body, readRange); err != nil {
+			return nil, err
+		}
+		if readRange != nil {
+			// Checksums cover the whole body, a range can't be verified.
+			return body, nil
+		}
+	}
+
+	return c.verifyBody(blobID, body, meta)
+}
+
+// Returns the whole body of a blob.
+func (c *Client) getBody(ctx context.Context, blobID string) (io.ReadCloser, error) {
+	req, err := c.makeJSONRequest(ctx, "GET", fmt.Sprintf("/blob/%s", blobID), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -294,15 +599,24 @@ func (c *Client) GetBody(blobID string, readRange *Range) (io.ReadCloser, error)
 
 	req.URL = redirectLocation
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
-	return resp.Body, nil
+	return c.countDownload(successBody(req, resp))
 }
 
+// GetMetadata returns the metadata of the specified blob.
 func (c *Client) GetMetadata(blobID string) (payload.BlobMeta, error) {
-	req, err := c.makeJSONRequest("GET", fmt.Sprintf("/blob/%s/metadata", blobID), nil)
+	return c.GetMetadataContext(context.Background(), blobID)
+}
+
+// GetMetadataContext is like GetMetadata but uses the provided context.
+func (c *Client) GetMetadataContext(ctx context.Context, blobID string) (_ payload.BlobMeta, err error) {
+	ctx, finish := c.startOperation(ctx, OpGetMetadata)
+	defer finish(&err)
+
+	req, err := c.makeJSONRequest(ctx, "GET", fmt.Sprintf("/blob/%s/metadata", blobID), nil)
 	if err != nil {
 		return payload.BlobMeta{}, err
 	}
@@ -321,7 +635,15 @@ func (c *Client) GetMetadata(blobID string) (payload.BlobMeta, error) {
 
 // Delete deletes a blob from the cluster.
 func (c *Client) Delete(blobID string) error {
-	req, err := c.makeJSONRequest("DELETE", fmt.Sprintf("/blob/%s", blobID), nil)
+	return c.DeleteContext(context.Background(), blobID)
+}
+
+// DeleteContext is like Delete but uses the provided context.
+func (c *Client) DeleteContext(ctx context.Context, blobID string) (err error) {
+	ctx, finish := c.startOperation(ctx, OpDelete)
+	defer finish(&err)
+
+	req, err := c.makeJSONRequest(ctx, "DELETE", fmt.Sprintf("/blob/%s", blobID), nil)
 	if err != nil {
 		return err
 	}
@@ -343,20 +665,47 @@ func (c *Client) Delete(blobID string) error {
 
 // Push creates a blob with the provided body and metadata to the cluster.
 // If the body is nil, the blob is created empty.
-func (c *Client) CreateBlob(body io.ReadCloser, meta payload.BlobMeta, size uint64) (string, error) {
-	return c.pushInternal("/blob", body, meta, size)
+//
+// If the blob was created but recording its checksum or flushing it with WithFsync failed,
+// its ID is returned along with the error, so the caller can retry or delete it.
+func (c *Client) CreateBlob(body io.ReadCloser, meta payload.BlobMeta, size uint64, opts ...UploadOption) (string, error) {
+	return c.CreateBlobContext(context.Background(), body, meta, size, opts...)
+}
+
+// CreateBlobContext is like CreateBlob but uses the provided context.
+func (c *Client) CreateBlobContext(ctx context.Context, body io.ReadCloser, meta payload.BlobMeta, size uint64, opts ...UploadOption) (_ string, err error) {
+	ctx, finish := c.startOperation(ctx, OpCreateBlob)
+	defer finish(&err)
+
+	return c.pushInternal(ctx, "", body, meta, size, newUploadOptions(opts))
 }
 
 // UpdateBlob updates the entirety of a blob's contents and metadata at once.
-func (c *Client) UpdateBlob(blobID string, body io.ReadCloser, meta payload.BlobMeta, size uint64) error {
-	_, err := c.pushInternal(fmt.Sprintf("/blob/%s", blobID), body, meta, size)
+func (c *Client) UpdateBlob(blobID string, body io.ReadCloser, meta payload.BlobMeta, size uint64, opts ...UploadOption) error {
+	return c.UpdateBlobContext(context.Background(), blobID, body, meta, size, opts...)
+}
+
+// UpdateBlobContext is like UpdateBlob but uses the provided context.
+func (c *Client) UpdateBlobContext(ctx context.Context, blobID string, body io.ReadCloser, meta payload.BlobMeta, size uint64, opts ...UploadOption) (err error) {
+	ctx, finish := c.startOperation(ctx, OpUpdateBlob)
+	defer finish(&err)
+
+	_, err = c.pushInternal(ctx, blobID, body, meta, size, newUploadOptions(opts))
 	return err
 }
 
 // UpdateMeta updates exclusively the blob metadata.
 func (c *Client) UpdateMeta(blobID string, meta payload.BlobMeta) error {
+	return c.UpdateMetaContext(context.Background(), blobID, meta)
+}
+
+// UpdateMetaContext is like UpdateMeta but uses the provided context.
+func (c *Client) UpdateMetaContext(ctx context.Context, blobID string, meta payload.BlobMeta) (err error) {
+	ctx, finish := c.startOperation(ctx, OpUpdateMeta)
+	defer finish(&err)
+
 	var response payload.MessageResponse
-	req, err := c.makeJSONRequest("PUT", fmt.Sprintf("/blob/%s/metadata", blobID), &meta)
+	req, err := c.makeJSONRequest(ctx, "PUT", fmt.Sprintf("/blob/%s/metadata", blobID), &meta)
 	if err != nil {
 		return err
 	}
@@ -367,7 +716,7 @@ func (c *Client) UpdateMeta(blobID string, meta payload.BlobMeta) error {
 	}
 
 	// Go doesn't like keeping the body for both requests, we'll rebuild from scratch.
-	req, err = c.makeJSONRequest("PUT", fmt.Sprintf("/blob/%s/metadata", blobID), &meta)
+	req, err = c.makeJSONRequest(ctx, "PUT", fmt.Sprintf("/blob/%s/metadata", blobID), &meta)
 	if err != nil {
 		return err
 	}
@@ -382,9 +731,17 @@ func (c *Client) UpdateMeta(blobID string, meta payload.BlobMeta) error {
 
 // Lists all storage nodes in the cluster.
 func (c *Client) ListStorageNodes() ([]payload.StorageNodeInfo, error) {
+	return c.ListStorageNodesContext(context.Background())
+}
+
+// ListStorageNodesContext is like ListStorageNodes but uses the provided context.
+func (c *Client) ListStorageNodesContext(ctx context.Context) (_ []payload.StorageNodeInfo, err error) {
+	ctx, finish := c.startOperation(ctx, OpListStorageNodes)
+	defer finish(&err)
+
 	var response payload.ListStorageNodesResponse
 
-	req, err := c.makeJSONRequest("GET", "/node/storage", nil)
+	req, err := c.makeJSONRequest(ctx, "GET", "/node/storage", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,348 @@
+package menmos_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/internal/menmostest"
+	"github.com/menmos/menmos-go/payload"
+)
+
+func Test_RegisterUser(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	token, err := client.RegisterUser(ctx, "ci-bot", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == "" {
+		t.Error("expected a token for the new user")
+	}
+
+	if _, err := menmos.New(server.URL, "ci-bot", "s3cret"); err != nil {
+		t.Errorf("failed to log in as the new user: %v", err)
+	}
+	if _, err := menmos.New(server.URL, "ci-bot", "password"); err == nil {
+		t.Error("expected the default password to be rejected for the new user")
+	}
+
+	if _, err := client.RegisterUser(ctx, "ci-bot", "other"); err == nil {
+		t.Error("expected registering an existing user to fail")
+	}
+	if _, err := client.RegisterUser(ctx, "", "other"); err == nil {
+		t.Error("expected an empty username to be rejected")
+	}
+}
+
+func Test_RoutingConfig(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	config, err := client.GetRoutingConfig(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config != nil {
+		t.Fatalf("expected no routing config, got %+v", config)
+	}
+
+	expected := payload.RoutingConfig{RoutingKey: "tenant", Routes: map[string]string{"acme": "storage-1"}}
+	if err := client.SetRoutingConfig(ctx, expected); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err = client.GetRoutingConfig(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config == nil || config.RoutingKey != "tenant" || config.Routes["acme"] != "storage-1" {
+		t.Errorf("unexpected routing config %+v", config)
+	}
+
+	if err := client.DeleteRoutingConfig(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if config, err = client.GetRoutingConfig(ctx); err != nil || config != nil {
+		t.Errorf("expected routing config to be deleted, got %+v (%v)", config, err)
+	}
+
+	if err := client.SetRoutingConfig(ctx, payload.RoutingConfig{}); err == nil {
+		t.Error("expected an empty routing key to be rejected")
+	}
+}
+
+func Test_ListMetadata(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	for _, blob := range []struct {
+		tags   []string
+		fields map[string]string
+	}{
+		{[]string{"image"}, map[string]string{"format": "png", "owner": "alice"}},
+		{[]string{"image", "public"}, map[string]string{"format": "jpg", "owner": "bob"}},
+		{[]string{"document"}, map[string]string{"format": "pdf", "owner": "alice"}},
+	} {
+		server.Put(nil, payload.BlobMeta{Tags: blob.tags, Fields: blob.fields})
+	}
+
+	all, err := client.ListMetadata(ctx, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all.Tags["image"] != 2 || all.Tags["public"] != 1 || all.Meta["owner"]["alice"] != 2 || len(all.Meta["format"]) != 3 {
+		t.Errorf("unexpected metadata %+v", all)
+	}
+
+	restricted, err := client.ListMetadata(ctx, []string{"public"}, []string{"owner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restricted.Tags) != 1 || len(restricted.Meta) != 1 || restricted.Meta["owner"]["bob"] != 1 {
+		t.Errorf("unexpected restricted metadata %+v", restricted)
+	}
+
+	images, err := client.ListMetadataForQuery(ctx, payload.NewExpression().AndTag("image"), nil, []string{"format"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if images.Tags["document"] != 0 || images.Tags["image"] != 2 || len(images.Meta) != 1 || images.Meta["format"]["pdf"] != 0 {
+		t.Errorf("unexpected query metadata %+v", images)
+	}
+}
+
+func Test_CopyMeta(t *testing.T) {
+	meta := payload.BlobMeta{Fields: map[string]string{"kind": "log"}, Tags: []string{"a"}}
+
+	copied := menmos.CopyMeta(meta)
+	copied.Fields["kind"] = "report"
+	copied.Tags[0] = "b"
+
+	if meta.Fields["kind"] != "log" || meta.Tags[0] != "a" {
+		t.Errorf("expected the original to be unchanged, got %+v", meta)
+	}
+
+	empty := menmos.CopyMeta(payload.BlobMeta{})
+	if empty.Fields == nil || empty.Tags == nil {
+		t.Errorf("expected initialized fields and tags, got %+v", empty)
+	}
+}
+
+func Test_Fsync(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	id, err := client.CreateBlobContext(ctx, ioutil.NopCloser(strings.NewReader("entry 1\n")), payload.NewBlobMeta(), 8, menmos.WithFsync())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blob, _ := server.Blob(id); blob.Fsyncs != 1 {
+		t.Errorf("expected 1 fsync after creation, got %d", blob.Fsyncs)
+	}
+
+	if err := client.UpdateBlob(id, ioutil.NopCloser(strings.NewReader("entry 2\n")), payload.NewBlobMeta(), 8); err != nil {
+		t.Fatal(err)
+	}
+	if blob, _ := server.Blob(id); blob.Fsyncs != 0 {
+		t.Errorf("expected no fsync without the option, got %d", blob.Fsyncs)
+	}
+
+	if err := client.Fsync(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if blob, _ := server.Blob(id); blob.Fsyncs != 1 {
+		t.Errorf("expected 1 explicit fsync, got %d", blob.Fsyncs)
+	}
+
+	if err := client.Fsync(ctx, "missing"); err == nil {
+		t.Error("expected fsync of a missing blob to fail")
+	}
+
+	server.Fail(http.MethodPost, "/fsync", http.StatusInternalServerError, 1)
+	id, err = client.CreateBlobContext(ctx, ioutil.NopCloser(strings.NewReader("entry 3\n")), payload.NewBlobMeta(), 8, menmos.WithFsync())
+	if err == nil {
+		t.Fatal("expected the fsync to fail")
+	}
+	if _, ok := server.Blob(id); !ok {
+		t.Fatalf("expected the ID of the created blob along with the error, got '%s'", id)
+	}
+	if err := client.Fsync(ctx, id); err != nil {
+		t.Errorf("failed to retry fsync: %v", err)
+	}
+}
+
+func Test_Stat(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	meta := payload.NewBlobMeta()
+	meta.Fields["kind"] = "log"
+	id := server.Put([]byte("hello world"), meta)
+
+	info, err := client.Stat(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != id || info.Size != 11 || info.Meta.Fields["kind"] != "log" {
+		t.Errorf("unexpected info %+v", info)
+	}
+	if info.StorageNodeID == "" {
+		t.Error("expected a storage node")
+	}
+	if time.Since(info.ModifiedAt) > time.Minute {
+		t.Errorf("unexpected modification time %v", info.ModifiedAt)
+	}
+
+	if _, err := client.Stat(ctx, "missing"); err == nil {
+		t.Error("expected stat of a missing blob to fail")
+	}
+}
+
+func Test_GetBodyClampsRange(t *testing.T) {
+	client, server := newTestClient(t)
+	id := server.Put([]byte("hello world"), payload.NewBlobMeta())
+
+	tests := []struct {
+		name     string
+		r        menmos.Range
+		expected string
+		// Whether the blob size must be fetched, which only happens when the range starts past the blob.
+		needsSize bool
+	}{
+		{"end past blob", menmos.Range{Start: 6, End: 100}, "world", false},
+		{"start past blob", menmos.Range{Start: 20, End: 30}, "", true},
+		{"within blob", menmos.Range{Start: 0, End: 4}, "hello", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(server.Requests())
+
+			r := tt.r
+			body, err := client.GetBody(id, &r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer body.Close()
+
+			data, err := ioutil.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, data)
+			}
+
+			fetchedSize := false
+			for _, request := range server.Requests()[before:] {
+				fetchedSize = fetchedSize || request.Method == http.MethodHead
+			}
+			if fetchedSize != tt.needsSize {
+				t.Errorf("expected size to be fetched: %v, got %v", tt.needsSize, fetchedSize)
+			}
+		})
+	}
+}
+
+func Test_GetBodyStatusError(t *testing.T) {
+	server := menmostest.NewServer()
+	defer server.Close()
+
+	// Without decompression, bodies are requested without fetching the metadata first.
+	client, err := menmos.New(server.URL, "admin", "password", menmos.WithMaxRetryCount(0), menmos.WithoutDecompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body, err := client.GetBody("missing", nil); err == nil {
+		body.Close()
+		t.Error("expected reading a missing blob to fail")
+	} else if !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected the status in the error, got: %v", err)
+	}
+
+	body, err := client.GetBody("missing", &menmos.Range{Start: 0, End: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	if _, err := ioutil.ReadAll(body); err == nil {
+		t.Error("expected reading a range of a missing blob to fail")
+	} else if !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected the status in the error, got: %v", err)
+	}
+}
+
+func Test_GetBodyRangeTruncated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/storage/") {
+			http.Redirect(w, r, "/storage"+r.URL.Path, http.StatusTemporaryRedirect)
+			return
+		}
+
+		// The connection drops after part of the requested range was sent.
+		w.Header().Set("Content-Range", "bytes 0-10/11")
+		w.Header().Set("Content-Length", "11")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("hello"))
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	defer server.Close()
+
+	client, err := menmos.NewWithToken(server.URL, menmostest.Token, menmos.WithMaxRetryCount(0), menmos.WithoutDecompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := client.GetBody("blob", &menmos.Range{Start: 0, End: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	data, err := ioutil.ReadAll(body)
+	if err == nil {
+		t.Errorf("expected a truncated response to fail, got %q", data)
+	}
+}
+
+func Test_TimeoutDoesNotCutBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/storage/") {
+			http.Redirect(w, r, "/storage"+r.URL.Path, http.StatusTemporaryRedirect)
+			return
+		}
+
+		// The headers come quickly, the body takes longer than the timeout.
+		w.Write([]byte("slow "))
+		w.(http.Flusher).Flush()
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	client, err := menmos.NewWithToken(server.URL, menmostest.Token, menmos.WithTimeout(50*time.Millisecond), menmos.WithoutDecompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := client.GetBody("blob", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil || string(data) != "slow body" {
+		t.Errorf("expected the whole body, got %q (%v)", data, err)
+	}
+}
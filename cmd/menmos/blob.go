@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/payload"
+	"github.com/pkg/errors"
+)
+
+type pushResult struct {
+	File string `json:"file"`
+	ID   string `json:"id"`
+}
+
+func openUpload(stdin io.Reader, path string) (io.ReadCloser, uint64, error) {
+	if path == "-" {
+		data, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "failed to read stdin")
+		}
+		return ioutil.NopCloser(bytes.NewReader(data)), uint64(len(data)), nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+
+	if info.IsDir() {
+		file.Close()
+		return nil, 0, errors.Errorf("'%s' is a directory", path)
+	}
+
+	return file, uint64(info.Size()), nil
+}
+
+func runPush(e *env, args []string) error {
+	tags := stringList{}
+	fields := keyValueList{}
+
+	fs := e.newFlagSet("push")
+	fs.Var(&tags, "tag", "tag to add to the blobs (repeatable)")
+	fs.Var(fields, "field", "KEY=VALUE field to add to the blobs (repeatable)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return usageErrorf("at least one file is required")
+	}
+
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	meta := payload.NewBlobMeta()
+	meta.Tags = append(meta.Tags, tags...)
+	for k, v := range fields {
+		meta.Fields[k] = v
+	}
+
+	var results []pushResult
+	for _, path := range fs.Args() {
+		id, err := pushFile(e, client, path, meta)
+		if err != nil {
+			return errors.Wrapf(err, "failed to push '%s'", path)
+		}
+		results = append(results, pushResult{File: path, ID: id})
+	}
+
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		rows = append(rows, []string{r.File, r.ID})
+	}
+	return e.out.print(results, []string{"FILE", "ID"}, rows)
+}
+
+func pushFile(e *env, client *menmos.Client, path string, meta payload.BlobMeta) (string, error) {
+	body, size, err := openUpload(e.stdin, path)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	return client.CreateBlob(body, meta, size)
+}
+
+func runGet(e *env, args []string) error {
+	fs := e.newFlagSet("get")
+	outPath := fs.String("out", "", "destination path (defaults to the blob ID in the current directory)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return usageErrorf("exactly one blob ID is required")
+	}
+	blobID := fs.Arg(0)
+
+	destination := *outPath
+	if destination == "" {
+		destination = blobID
+	}
+
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	body, err := client.GetBody(blobID, nil)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(destination), ".menmos-get-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := io.Copy(tmpFile, body); err != nil {
+		tmpFile.Close()
+		return errors.Wrap(err, "failed to download blob")
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	// Temporary files are only readable by their owner, downloads get the permissions of a regular file.
+	if err := os.Chmod(tmpFile.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), destination)
+}
+
+func runCat(e *env, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("at least one blob ID is required")
+	}
+
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	for _, blobID := range args {
+		body, err := client.GetBody(blobID, nil)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(e.stdout, body)
+		body.Close()
+		if err != nil {
+			return errors.Wrapf(err, "failed to read blob '%s'", blobID)
+		}
+	}
+
+	return nil
+}
+
+func runRm(e *env, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("at least one blob ID is required")
+	}
+
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	for _, blobID := range args {
+		if err := client.Delete(blobID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type metaResult struct {
+	ID   string           `json:"id"`
+	Meta payload.BlobMeta `json:"meta"`
+}
+
+func printMeta(e *env, blobID string, meta payload.BlobMeta) error {
+	tags := append([]string{}, meta.Tags...)
+	sort.Strings(tags)
+
+	rows := [][]string{
+		{"tags", strings.Join(tags, ",")},
+		{"fields", formatFields(meta.Fields)},
+	}
+	return e.out.print(metaResult{ID: blobID, Meta: meta}, nil, rows)
+}
+
+func runMeta(e *env, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("a subcommand is required")
+	}
+
+	switch args[0] {
+	case "get":
+		return runMetaGet(e, args[1:])
+	case "set":
+		return runMetaSet(e, args[1:])
+	}
+
+	return usageErrorf("unknown subcommand '%s'", args[0])
+}
+
+func runMetaGet(e *env, args []string) error {
+	if len(args) != 1 {
+		return usageErrorf("exactly one blob ID is required")
+	}
+
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	meta, err := client.GetMetadata(args[0])
+	if err != nil {
+		return err
+	}
+
+	return printMeta(e, args[0], meta)
+}
+
+func runMetaSet(e *env, args []string) error {
+	tags := stringList{}
+	fields := keyValueList{}
+
+	fs := e.newFlagSet("meta set")
+	fs.Var(&tags, "tag", "tag to add (repeatable)")
+	fs.Var(fields, "field", "KEY=VALUE field to set (repeatable)")
+	replace := fs.Bool("replace", false, "replace the existing metadata instead of merging into it")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return usageErrorf("exactly one blob ID is required")
+	}
+	blobID := fs.Arg(0)
+
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	meta := payload.NewBlobMeta()
+	if !*replace {
+		if meta, err = client.GetMetadata(blobID); err != nil {
+			return err
+		}
+		if meta.Fields == nil {
+			meta.Fields = make(map[string]string)
+		}
+	}
+
+	for _, tag := range tags {
+		if !containsString(meta.Tags, tag) {
+			meta.Tags = append(meta.Tags, tag)
+		}
+	}
+	for k, v := range fields {
+		meta.Fields[k] = v
+	}
+
+	if err := client.UpdateMeta(blobID, meta); err != nil {
+		return err
+	}
+
+	return printMeta(e, blobID, meta)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
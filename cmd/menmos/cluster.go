@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	menmos "github.com/menmos/menmos-go"
+)
+
+func runNodes(e *env, args []string) error {
+	if len(args) != 0 {
+		return usageErrorf("unexpected arguments")
+	}
+
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	nodes, err := client.ListStorageNodes()
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(nodes))
+	for _, node := range nodes {
+		rows = append(rows, []string{
+			node.ID,
+			strconv.FormatUint(uint64(node.Port), 10),
+			strconv.FormatUint(node.Size, 10),
+			strconv.FormatUint(node.AvailableSpace, 10),
+		})
+	}
+
+	return e.out.print(nodes, []string{"ID", "PORT", "SIZE", "AVAILABLE"}, rows)
+}
+
+type healthResult struct {
+	Healthy bool `json:"healthy"`
+}
+
+func runHealth(e *env, args []string) error {
+	if len(args) != 0 {
+		return usageErrorf("unexpected arguments")
+	}
+
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	healthy, err := client.IsHealthy()
+	if err != nil {
+		return err
+	}
+
+	status := "healthy"
+	if !healthy {
+		status = "unhealthy"
+	}
+
+	return e.out.print(healthResult{Healthy: healthy}, nil, [][]string{{status}})
+}
+
+type versionResult struct {
+	Client string `json:"client"`
+	Server string `json:"server"`
+}
+
+func runVersion(e *env, args []string) error {
+	if len(args) != 0 {
+		return usageErrorf("unexpected arguments")
+	}
+
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	version, err := client.ServerVersion(context.Background())
+	if err != nil {
+		return err
+	}
+
+	result := versionResult{Client: menmos.Version, Server: version.String()}
+	return e.out.print(result, []string{"CLIENT", "SERVER"}, [][]string{{result.Client, result.Server}})
+}
@@ -0,0 +1,211 @@
+// Command menmos is a command-line client for menmos clusters.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/config"
+)
+
+const (
+	exitSuccess = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// usageError signals that the command line was invalid.
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &usageError{message: fmt.Sprintf(format, args...)}
+}
+
+// env holds the global state shared by all subcommands.
+type env struct {
+	profileName string
+	configPath  string
+	out         *printer
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (e *env) loadConfig() (*config.Config, error) {
+	if e.configPath != "" {
+		return config.LoadFromFile(e.configPath)
+	}
+	return config.LoadOrCreateDefault()
+}
+
+func (e *env) saveConfig(cfg *config.Config) error {
+	if e.configPath != "" {
+		return cfg.Save(e.configPath)
+	}
+	return cfg.SaveDefault()
+}
+
+func (e *env) client() (*menmos.Client, error) {
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := cfg.GetProfile(e.profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return menmos.NewFromProfileConfig(profile)
+}
+
+type command struct {
+	usage       string
+	description string
+	run         func(e *env, args []string) error
+}
+
+var commands = map[string]command{
+	"push":    {"push [-tag TAG]... [-field KEY=VALUE]... FILE...", "upload files as new blobs", runPush},
+	"get":     {"get [-out PATH] BLOB_ID", "download a blob to a file", runGet},
+	"cat":     {"cat BLOB_ID...", "write blob contents to stdout", runCat},
+	"rm":      {"rm BLOB_ID...", "delete blobs", runRm},
+	"meta":    {"meta get BLOB_ID | meta set [-replace] [-tag TAG]... [-field KEY=VALUE]... BLOB_ID", "read or update blob metadata", runMeta},
+	"query":   {"query [-from N] [-size N] [-facets] [-tag TAG]... [-field KEY=VALUE]... [-has KEY]... [EXPRESSION]", "query blobs", runQuery},
+	"sync":    {"sync [-direction push|pull|both] [-conflicts skip|local|remote|newest] [-dry-run] [-keep-deleted] [-tag TAG]... [-field KEY=VALUE]... [-has KEY]... DIR", "synchronize a directory with blobs", runSync},
+	"nodes":   {"nodes", "list storage nodes", runNodes},
+	"health":  {"health", "check the health of the cluster", runHealth},
+	"version": {"version", "show the client and server versions", runVersion},
+	"profile": {"profile add [flags] NAME | profile list | profile rm NAME", "manage client profiles", runProfile},
+}
+
+func usage(stderr io.Writer, global *flag.FlagSet) {
+	fmt.Fprintf(stderr, "Usage: menmos [-profile NAME] [-config PATH] [-o table|json] COMMAND [ARGS]\n\nCommands:\n")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(stderr, "  %-8s %s\n", name, commands[name].description)
+	}
+
+	fmt.Fprintf(stderr, "\nGlobal flags:\n")
+	global.PrintDefaults()
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes a command line and returns the exit code of the command.
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	e := &env{stdin: stdin, stdout: stdout, stderr: stderr}
+
+	global := flag.NewFlagSet("menmos", flag.ContinueOnError)
+	global.SetOutput(stderr)
+
+	var outputFormat string
+	global.StringVar(&e.profileName, "profile", os.Getenv("MENMOS_PROFILE"), "name of the profile to use (defaults to the configured default profile)")
+	global.StringVar(&e.configPath, "config", os.Getenv("MENMOS_CONFIG"), "path of the client configuration file")
+	global.StringVar(&outputFormat, "o", formatTable, "output format (table or json)")
+	global.Usage = func() { usage(stderr, global) }
+	if err := global.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if global.NArg() == 0 {
+		usage(stderr, global)
+		return exitUsage
+	}
+
+	out, err := newPrinter(stdout, outputFormat)
+	if err != nil {
+		fmt.Fprintf(stderr, "menmos: %v\n", err)
+		return exitUsage
+	}
+	e.out = out
+
+	name := global.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "menmos: unknown command '%s'\n\n", name)
+		usage(stderr, global)
+		return exitUsage
+	}
+
+	if err := cmd.run(e, global.Args()[1:]); err != nil {
+		fmt.Fprintf(stderr, "menmos %s: %v\n", name, err)
+		if _, ok := err.(*usageError); ok {
+			fmt.Fprintf(stderr, "usage: menmos %s\n", cmd.usage)
+			return exitUsage
+		}
+		return exitFailure
+	}
+
+	return exitSuccess
+}
+
+// newFlagSet returns a flag set for a subcommand whose parsing errors are reported as usage errors.
+func (e *env) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	return fs
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return usageErrorf("help requested")
+		}
+		return usageErrorf("%v", err)
+	}
+	return nil
+}
+
+// stringList is a flag that can be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// keyValueList is a repeatable KEY=VALUE flag.
+type keyValueList map[string]string
+
+func (l keyValueList) String() string {
+	pairs := make([]string, 0, len(l))
+	for k, v := range l {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (l keyValueList) Set(value string) error {
+	idx := strings.Index(value, "=")
+	if idx <= 0 {
+		return fmt.Errorf("expected KEY=VALUE, got '%s'", value)
+	}
+	l[value[:idx]] = value[idx+1:]
+	return nil
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/menmos/menmos-go/config"
+	"github.com/menmos/menmos-go/internal/menmostest"
+	"github.com/menmos/menmos-go/payload"
+)
+
+// testCLI runs commands against a fake cluster, with a configuration file holding a default profile for it.
+type testCLI struct {
+	server     *menmostest.Server
+	dir        string
+	configPath string
+}
+
+func newTestCLI(t *testing.T) *testCLI {
+	server := menmostest.NewServer()
+	t.Cleanup(server.Close)
+
+	dir, err := ioutil.TempDir("", "menmos-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	cfg := &config.Config{DefaultProfile: "test"}
+	cfg.SetProfile("test", config.Profile{Host: server.URL, Token: menmostest.Token})
+
+	configPath := filepath.Join(dir, "client.toml")
+	if err := cfg.Save(configPath); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCLI{server: server, dir: dir, configPath: configPath}
+}
+
+type cliResult struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+func (c *testCLI) run(stdin string, args ...string) cliResult {
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"-config", c.configPath}, args...), strings.NewReader(stdin), &stdout, &stderr)
+	return cliResult{code: code, stdout: stdout.String(), stderr: stderr.String()}
+}
+
+func (c *testCLI) writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(c.dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_ExitCodes(t *testing.T) {
+	cli := newTestCLI(t)
+	id := cli.server.Put([]byte("data"), payload.NewBlobMeta())
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"no command", nil, exitUsage},
+		{"unknown command", []string{"frobnicate"}, exitUsage},
+		{"unknown global flag", []string{"-nope", "health"}, exitUsage},
+		{"unknown output format", []string{"-o", "yaml", "health"}, exitUsage},
+		{"unknown command flag", []string{"push", "-nope", "file"}, exitUsage},
+		{"missing arguments", []string{"push"}, exitUsage},
+		{"too many arguments", []string{"get", id, "other"}, exitUsage},
+		{"invalid field", []string{"meta", "set", "-field", "novalue", id}, exitUsage},
+		{"unknown subcommand", []string{"meta", "delete", id}, exitUsage},
+		{"unknown profile", []string{"-profile", "missing", "health"}, exitFailure},
+		{"missing blob", []string{"cat", "missing"}, exitFailure},
+		{"missing file", []string{"push", filepath.Join(cli.dir, "missing")}, exitFailure},
+		{"success", []string{"health"}, exitSuccess},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := cli.run("", tt.args...)
+			if result.code != tt.code {
+				t.Errorf("expected exit code %d, got %d (stderr: %s)", tt.code, result.code, result.stderr)
+			}
+			if tt.code != exitSuccess && result.stderr == "" {
+				t.Error("expected an error message on stderr")
+			}
+		})
+	}
+}
+
+func Test_PushAndRead(t *testing.T) {
+	cli := newTestCLI(t)
+	path := cli.writeFile(t, "hello.txt", "hello world")
+
+	result := cli.run("", "-o", "json", "push", "-tag", "greeting", "-field", "lang=en", path)
+	if result.code != exitSuccess {
+		t.Fatalf("push failed: %s", result.stderr)
+	}
+
+	var pushed []pushResult
+	if err := json.Unmarshal([]byte(result.stdout), &pushed); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", result.stdout, err)
+	}
+	if len(pushed) != 1 || pushed[0].File != path {
+		t.Fatalf("unexpected push results %+v", pushed)
+	}
+	id := pushed[0].ID
+
+	blob, ok := cli.server.Blob(id)
+	if !ok || string(blob.Data) != "hello world" {
+		t.Fatalf("blob wasn't uploaded: %+v", blob)
+	}
+	if len(blob.Meta.Tags) != 1 || blob.Meta.Tags[0] != "greeting" || blob.Meta.Fields["lang"] != "en" {
+		t.Errorf("unexpected metadata %+v", blob.Meta)
+	}
+
+	if result := cli.run("", "cat", id); result.code != exitSuccess || result.stdout != "hello world" {
+		t.Errorf("unexpected cat output %q (%s)", result.stdout, result.stderr)
+	}
+
+	result = cli.run("from stdin", "push", "-")
+	if result.code != exitSuccess {
+		t.Fatalf("push from stdin failed: %s", result.stderr)
+	}
+	fields := strings.Fields(strings.Split(result.stdout, "\n")[1])
+	if len(fields) != 2 || fields[0] != "-" {
+		t.Fatalf("unexpected table output %q", result.stdout)
+	}
+	if blob, _ := cli.server.Blob(fields[1]); string(blob.Data) != "from stdin" {
+		t.Errorf("unexpected content %q", blob.Data)
+	}
+
+	cli.server.Fail(http.MethodPost, "/blob", http.StatusInternalServerError, 1)
+	if result := cli.run("", "push", path); result.code != exitFailure || !strings.Contains(result.stderr, path) {
+		t.Errorf("expected a failed upload to be reported, got %d (%s)", result.code, result.stderr)
+	}
+}
+
+func Test_Get(t *testing.T) {
+	cli := newTestCLI(t)
+	id := cli.server.Put([]byte("downloaded"), payload.NewBlobMeta())
+
+	destination := filepath.Join(cli.dir, "out.txt")
+	if result := cli.run("", "get", "-out", destination, id); result.code != exitSuccess {
+		t.Fatalf("get failed: %s", result.stderr)
+	}
+
+	data, err := ioutil.ReadFile(destination)
+	if err != nil || string(data) != "downloaded" {
+		t.Errorf("unexpected content %q (%v)", data, err)
+	}
+	if info, err := os.Stat(destination); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("expected a regular file mode, got %v (%v)", info.Mode(), err)
+	}
+
+	if result := cli.run("", "get", "-out", filepath.Join(cli.dir, "missing.txt"), "missing"); result.code != exitFailure {
+		t.Errorf("expected downloading a missing blob to fail, got %d", result.code)
+	}
+	if _, err := os.Stat(filepath.Join(cli.dir, "missing.txt")); !os.IsNotExist(err) {
+		t.Error("expected no file for a failed download")
+	}
+}
+
+func Test_Meta(t *testing.T) {
+	cli := newTestCLI(t)
+	meta := payload.NewBlobMeta()
+	meta.Tags = append(meta.Tags, "old")
+	meta.Fields["kind"] = "log"
+	id := cli.server.Put([]byte("data"), meta)
+
+	result := cli.run("", "meta", "get", id)
+	if result.code != exitSuccess {
+		t.Fatal(result.stderr)
+	}
+	if !strings.Contains(result.stdout, "tags") || !strings.Contains(result.stdout, "kind=log") {
+		t.Errorf("unexpected table output %q", result.stdout)
+	}
+
+	if result := cli.run("", "meta", "set", "-tag", "new", "-field", "owner=ops", id); result.code != exitSuccess {
+		t.Fatal(result.stderr)
+	}
+	blob, _ := cli.server.Blob(id)
+	if len(blob.Meta.Tags) != 2 || blob.Meta.Fields["kind"] != "log" || blob.Meta.Fields["owner"] != "ops" {
+		t.Errorf("expected the metadata to be merged, got %+v", blob.Meta)
+	}
+
+	result = cli.run("", "-o", "json", "meta", "set", "-replace", "-field", "owner=dev", id)
+	if result.code != exitSuccess {
+		t.Fatal(result.stderr)
+	}
+	var printed metaResult
+	if err := json.Unmarshal([]byte(result.stdout), &printed); err != nil || printed.ID != id {
+		t.Fatalf("unexpected JSON output %q (%v)", result.stdout, err)
+	}
+	blob, _ = cli.server.Blob(id)
+	if len(blob.Meta.Tags) != 0 || len(blob.Meta.Fields) != 1 || blob.Meta.Fields["owner"] != "dev" {
+		t.Errorf("expected the metadata to be replaced, got %+v", blob.Meta)
+	}
+}
+
+func Test_QueryAndRm(t *testing.T) {
+	cli := newTestCLI(t)
+	meta := payload.NewBlobMeta()
+	meta.Tags = append(meta.Tags, "report")
+	first := cli.server.Put([]byte("a"), meta)
+	second := cli.server.Put([]byte("b"), meta)
+	cli.server.Put([]byte("c"), payload.NewBlobMeta())
+
+	result := cli.run("", "query", "-tag", "report")
+	if result.code != exitSuccess {
+		t.Fatal(result.stderr)
+	}
+	if !strings.Contains(result.stdout, first) || !strings.Contains(result.stdout, second) || !strings.Contains(result.stdout, "2 of 2 hits") {
+		t.Errorf("unexpected table output %q", result.stdout)
+	}
+
+	result = cli.run("", "-o", "json", "query", "-tag", "report")
+	var response payload.QueryResponse
+	if err := json.Unmarshal([]byte(result.stdout), &response); err != nil || response.Total != 2 {
+		t.Errorf("unexpected JSON output %q (%v)", result.stdout, err)
+	}
+
+	if result := cli.run("", "query", "-tag", "report", "extra expression"); result.code != exitUsage {
+		t.Errorf("expected combining an expression with flags to be rejected, got %d", result.code)
+	}
+
+	if result := cli.run("", "rm", first, second); result.code != exitSuccess {
+		t.Fatal(result.stderr)
+	}
+	if cli.server.BlobCount() != 1 {
+		t.Errorf("expected 1 remaining blob, got %d", cli.server.BlobCount())
+	}
+}
+
+func Test_Profile(t *testing.T) {
+	cli := newTestCLI(t)
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"missing host", []string{"profile", "add", "-token", "abc", "prod"}, exitUsage},
+		{"missing credentials", []string{"profile", "add", "-host", "https://menmos.example.com", "prod"}, exitUsage},
+		{"missing name", []string{"profile", "add", "-host", "https://menmos.example.com", "-token", "abc"}, exitUsage},
+		{"token", []string{"profile", "add", "-host", "https://menmos.example.com", "-token", "abc", "prod"}, exitSuccess},
+		{"password env", []string{"profile", "add", "-host", "http://localhost:3030", "-username", "admin", "-password-env", "MENMOS_PASSWORD", "-default", "dev"}, exitSuccess},
+		{"remove missing", []string{"profile", "rm", "staging"}, exitFailure},
+	}
+
+	for _, tt := range tests {
+		if result := cli.run("", tt.args...); result.code != tt.code {
+			t.Errorf("%s: expected exit code %d, got %d (stderr: %s)", tt.name, tt.code, result.code, result.stderr)
+		}
+	}
+
+	cfg, err := config.LoadFromFile(cli.configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DefaultProfile != "dev" {
+		t.Errorf("expected 'dev' to be the default profile, got '%s'", cfg.DefaultProfile)
+	}
+	if prod := cfg.Profiles["prod"]; prod.Host != "https://menmos.example.com" || prod.Token != "abc" {
+		t.Errorf("unexpected profile %+v", prod)
+	}
+
+	result := cli.run("", "-o", "json", "profile", "list")
+	var entries []profileEntry
+	if err := json.Unmarshal([]byte(result.stdout), &entries); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", result.stdout, err)
+	}
+	if len(entries) != 3 || entries[0].Name != "dev" || !entries[0].Default || entries[0].Auth != "password-env" || entries[1].Auth != "token" {
+		t.Errorf("unexpected profiles %+v", entries)
+	}
+
+	if result := cli.run("", "profile", "rm", "prod"); result.code != exitSuccess {
+		t.Fatal(result.stderr)
+	}
+	result = cli.run("", "profile", "list")
+	if strings.Contains(result.stdout, "prod") || !strings.Contains(result.stdout, "* ") {
+		t.Errorf("unexpected table output %q", result.stdout)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/menmos/menmos-go/config"
+)
+
+func runProfile(e *env, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("a subcommand is required")
+	}
+
+	switch args[0] {
+	case "add":
+		return runProfileAdd(e, args[1:])
+	case "list":
+		return runProfileList(e, args[1:])
+	case "rm":
+		return runProfileRm(e, args[1:])
+	}
+
+	return usageErrorf("unknown subcommand '%s'", args[0])
+}
+
+func runProfileAdd(e *env, args []string) error {
+	var profile config.Profile
+
+	fs := e.newFlagSet("profile add")
+	fs.StringVar(&profile.Host, "host", "", "URL of the menmos directory")
+	fs.StringVar(&profile.Username, "username", "", "username to authenticate with")
+	fs.StringVar(&profile.Password, "password", "", "password, stored in plaintext (prefer the indirect password flags)")
+	fs.StringVar(&profile.PasswordCommand, "password-command", "", "command printing the password")
+	fs.StringVar(&profile.PasswordFile, "password-file", "", "file containing the password")
+	fs.StringVar(&profile.PasswordEnv, "password-env", "", "environment variable containing the password")
+	fs.StringVar(&profile.Token, "token", "", "pre-issued token, stored in plaintext")
+	fs.StringVar(&profile.TokenFile, "token-file", "", "file containing a pre-issued token")
+	fs.StringVar(&profile.CAFile, "ca-file", "", "PEM bundle of additional trusted certificate authorities")
+	fs.StringVar(&profile.TLSCertFile, "tls-cert-file", "", "client certificate for mutual TLS")
+	fs.StringVar(&profile.TLSKeyFile, "tls-key-file", "", "client private key for mutual TLS")
+	setDefault := fs.Bool("default", false, "make this the default profile")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return usageErrorf("exactly one profile name is required")
+	}
+	name := fs.Arg(0)
+
+	if profile.Host == "" {
+		return usageErrorf("-host is required")
+	}
+
+	if profile.UsesToken() {
+		if _, err := profile.ResolveToken(); err != nil {
+			return usageErrorf("%v", err)
+		}
+	} else if profile.Username == "" {
+		return usageErrorf("-username is required unless a token is provided")
+	}
+
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg.SetProfile(name, profile)
+	if *setDefault || cfg.DefaultProfile == "" {
+		cfg.DefaultProfile = name
+	}
+
+	return e.saveConfig(cfg)
+}
+
+type profileEntry struct {
+	Name     string `json:"name"`
+	Host     string `json:"host"`
+	Username string `json:"username,omitempty"`
+	Auth     string `json:"auth"`
+	Default  bool   `json:"default"`
+}
+
+func authKind(profile config.Profile) string {
+	switch {
+	case profile.UsesToken():
+		return "token"
+	case profile.PasswordCommand != "":
+		return "password-command"
+	case profile.PasswordFile != "":
+		return "password-file"
+	case profile.PasswordEnv != "":
+		return "password-env"
+	}
+	return "password"
+}
+
+func runProfileList(e *env, args []string) error {
+	if len(args) != 0 {
+		return usageErrorf("unexpected arguments")
+	}
+
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := make([]profileEntry, 0, len(names))
+	rows := make([][]string, 0, len(names))
+	for _, name := range names {
+		profile := cfg.Profiles[name]
+		entry := profileEntry{
+			Name:     name,
+			Host:     profile.Host,
+			Username: profile.Username,
+			Auth:     authKind(profile),
+			Default:  name == cfg.DefaultProfile,
+		}
+		entries = append(entries, entry)
+
+		defaultMarker := ""
+		if entry.Default {
+			defaultMarker = "*"
+		}
+		rows = append(rows, []string{defaultMarker, entry.Name, entry.Host, entry.Username, entry.Auth})
+	}
+
+	return e.out.print(entries, []string{"", "NAME", "HOST", "USERNAME", "AUTH"}, rows)
+}
+
+func runProfileRm(e *env, args []string) error {
+	if len(args) != 1 {
+		return usageErrorf("exactly one profile name is required")
+	}
+
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.RemoveProfile(args[0]); err != nil {
+		return err
+	}
+
+	return e.saveConfig(cfg)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/menmos/menmos-go/payload"
+)
+
+// expressionFlags are the flags building a structured expression.
+type expressionFlags struct {
+	tags   stringList
+	keys   stringList
+	fields keyValueList
+}
+
+func newExpressionFlags(fs *flag.FlagSet) *expressionFlags {
+	f := &expressionFlags{fields: keyValueList{}}
+	fs.Var(&f.tags, "tag", "only match blobs with this tag (repeatable)")
+	fs.Var(f.fields, "field", "only match blobs where KEY=VALUE (repeatable)")
+	fs.Var(&f.keys, "has", "only match blobs that have this field (repeatable)")
+	return f
+}
+
+func (f *expressionFlags) isEmpty() bool {
+	return len(f.tags) == 0 && len(f.keys) == 0 && len(f.fields) == 0
+}
+
+func (f *expressionFlags) expression() payload.Expression {
+	expr := payload.NewExpression()
+	for _, tag := range f.tags {
+		expr = expr.AndTag(tag)
+	}
+	for _, key := range f.keys {
+		expr = expr.AndHasKey(key)
+	}
+
+	fieldKeys := make([]string, 0, len(f.fields))
+	for k := range f.fields {
+		fieldKeys = append(fieldKeys, k)
+	}
+	sort.Strings(fieldKeys)
+	for _, k := range fieldKeys {
+		expr = expr.AndKeyValue(k, f.fields[k])
+	}
+
+	return expr
+}
+
+func runQuery(e *env, args []string) error {
+	fs := e.newFlagSet("query")
+	from := fs.Uint("from", 0, "index of the first hit to return")
+	size := fs.Uint("size", 20, "maximum number of hits to return")
+	facets := fs.Bool("facets", false, "also return tag and field facets")
+	exprFlags := newExpressionFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	structured := !exprFlags.isEmpty()
+	if structured && fs.NArg() > 0 {
+		return usageErrorf("an expression cannot be combined with -tag, -field or -has")
+	}
+	if fs.NArg() > 1 {
+		return usageErrorf("the expression must be passed as a single argument")
+	}
+
+	var query *payload.Query
+	if structured {
+		query = payload.NewStructuredQuery(exprFlags.expression())
+	} else {
+		query = payload.NewUnstructuredQuery(fs.Arg(0))
+	}
+	query = query.WithFrom(uint32(*from)).WithSize(uint32(*size)).WithFacets(*facets).WithSignURLs(false)
+
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	response, err := client.Query(query)
+	if err != nil {
+		return err
+	}
+
+	if e.out.format == formatJSON {
+		return e.out.print(response, nil, nil)
+	}
+
+	rows := make([][]string, 0, len(response.Hits))
+	for _, hit := range response.Hits {
+		hitTags := append([]string{}, hit.Metadata.Tags...)
+		sort.Strings(hitTags)
+		rows = append(rows, []string{hit.ID, strings.Join(hitTags, ","), formatFields(hit.Metadata.Fields)})
+	}
+
+	if err := e.out.print(response, []string{"ID", "TAGS", "FIELDS"}, rows); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.out.w, "\n%d of %d hits\n", response.Count, response.Total)
+	return nil
+}
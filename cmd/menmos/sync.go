@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/payload"
+)
+
+var syncDirections = map[string]menmos.SyncDirection{
+	"push": menmos.SyncPush,
+	"pull": menmos.SyncPull,
+	"both": menmos.SyncBidirectional,
+}
+
+var conflictPolicies = map[string]menmos.ConflictPolicy{
+	"skip":   menmos.ConflictSkip,
+	"local":  menmos.ConflictPreferLocal,
+	"remote": menmos.ConflictPreferRemote,
+	"newest": menmos.ConflictPreferNewest,
+}
+
+func runSync(e *env, args []string) error {
+	fs := e.newFlagSet("sync")
+	direction := fs.String("direction", "push", "sync direction (push, pull or both)")
+	conflicts := fs.String("conflicts", "skip", "conflict policy for bidirectional syncs (skip, local, remote or newest)")
+	dryRun := fs.Bool("dry-run", false, "print the plan without applying it")
+	keepDeleted := fs.Bool("keep-deleted", false, "never propagate deletions")
+	exprFlags := newExpressionFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return usageErrorf("exactly one directory is required")
+	}
+	root := fs.Arg(0)
+
+	opts := menmos.SyncOptions{PreserveDeleted: *keepDeleted, Meta: payload.NewBlobMeta()}
+
+	var ok bool
+	if opts.Direction, ok = syncDirections[*direction]; !ok {
+		return usageErrorf("unknown direction '%s'", *direction)
+	}
+	if opts.Conflicts, ok = conflictPolicies[*conflicts]; !ok {
+		return usageErrorf("unknown conflict policy '%s'", *conflicts)
+	}
+
+	if exprFlags.isEmpty() {
+		return usageErrorf("at least one of -tag, -field or -has is required to select the synced blobs")
+	}
+
+	// Blobs created by the sync must match the expression, otherwise they wouldn't be found by the next sync.
+	opts.Meta.Tags = append(opts.Meta.Tags, exprFlags.tags...)
+	for k, v := range exprFlags.fields {
+		opts.Meta.Fields[k] = v
+	}
+	for _, key := range exprFlags.keys {
+		if _, ok := opts.Meta.Fields[key]; !ok {
+			opts.Meta.Fields[key] = ""
+		}
+	}
+
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	plan, err := client.PlanSync(ctx, root, exprFlags.expression(), opts)
+	if err != nil {
+		return err
+	}
+
+	if e.out.format == formatJSON {
+		if err := e.out.print(plan, nil, nil); err != nil {
+			return err
+		}
+	} else if _, err := plan.WriteTo(e.stdout); err != nil {
+		return err
+	}
+
+	if *dryRun {
+		return nil
+	}
+
+	if err := client.ApplySync(ctx, plan); err != nil {
+		return err
+	}
+
+	if conflicts := plan.Conflicts(); len(conflicts) > 0 {
+		return fmt.Errorf("%d conflicts left unresolved", len(conflicts))
+	}
+
+	return nil
+}
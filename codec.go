@@ -0,0 +1,244 @@
+package menmos
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/menmos/menmos-go/payload"
+	"github.com/pkg/errors"
+)
+
+// CodecField is the reserved metadata field storing the name of the codec a blob body is encoded with.
+const CodecField = "_codec"
+
+// OriginalSizeField is the reserved metadata field storing the size of a blob body before encoding.
+const OriginalSizeField = "_original_size"
+
+// ErrUnknownCodec is returned when reading a blob encoded with a codec the client doesn't know.
+var ErrUnknownCodec = errors.New("unknown codec")
+
+// A Codec compresses blob bodies on upload and decompresses them on download.
+//
+// Gzip is built in, and blobs encoded with it are decompressed by default.
+// Other algorithms (e.g. zstd) can be used by implementing this interface
+// and passing the codec to WithCompression or WithDecompression.
+type Codec interface {
+	// Name is the identifier of the codec, recorded in the CodecField field of encoded blobs.
+	Name() string
+
+	// NewWriter returns a writer compressing data to w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+
+	// NewReader returns a reader decompressing data from r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+type gzipCodec struct {
+	level int
+}
+
+// Gzip is the gzip codec, using the default compression level.
+var Gzip Codec = gzipCodec{level: gzip.DefaultCompression}
+
+// NewGzipCodec returns a gzip codec using the specified compression level.
+func NewGzipCodec(level int) (Codec, error) {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return nil, fmt.Errorf("invalid gzip compression level: %d", level)
+	}
+	return gzipCodec{level: level}, nil
+}
+
+func (c gzipCodec) Name() string {
+	return "gzip"
+}
+
+func (c gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, c.level)
+}
+
+func (c gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// WithCompression makes the client compress uploaded bodies with the specified codec,
+// and transparently decompress the bodies of blobs encoded with it.
+//
+// The codec and the original size of the body are recorded in the CodecField and OriginalSizeField fields.
+// Compressed bodies are spooled to a temporary file, since their size must be known before the upload.
+func WithCompression(codec Codec) Option {
+	return func(o *clientOptions) error {
+		if codec == nil {
+			return errors.New("compression codec cannot be nil")
+		}
+		o.compression = codec
+		return WithDecompression(codec)(o)
+	}
+}
+
+// WithDecompression makes the client transparently decompress the bodies of blobs encoded with one of
+// the specified codecs, in addition to gzip, without compressing uploads.
+func WithDecompression(codecs ...Codec) Option {
+	return func(o *clientOptions) error {
+		if o.codecs == nil {
+			o.codecs = make(map[string]Codec)
+		}
+		for _, codec := range codecs {
+			if codec == nil {
+				return errors.New("decompression codec cannot be nil")
+			}
+			o.codecs[codec.Name()] = codec
+		}
+		return nil
+	}
+}
+
+// WithoutDecompression makes the client return the raw bodies of encoded blobs.
+//
+// Since gzip is decoded by default, every GetBody fetches the blob metadata before its body to find its codec,
+// which costs an extra request to the directory, range reads and menmosfs reads included.
+// Without decompression or checksums, GetBody fetches the body directly: use this option when the blobs read
+// by the client are never encoded.
+func WithoutDecompression() Option {
+	return func(o *clientOptions) error {
+		o.codecs = nil
+		return nil
+	}
+}
+
+func removeCodecFields(meta payload.BlobMeta) {
+	delete(meta.Fields, CodecField)
+	delete(meta.Fields, OriginalSizeField)
+}
+
+// Returns the codec a blob is encoded with, or nil if it isn't encoded.
+func (c *Client) blobCodec(blobID string, meta payload.BlobMeta) (Codec, error) {
+	name, ok := meta.Fields[CodecField]
+	if !ok {
+		return nil, nil
+	}
+
+	codec, ok := c.codecs[name]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnknownCodec, "blob '%s' is encoded with '%s'", blobID, name)
+	}
+	return codec, nil
+}
+
+// compressedBody is a compressed body spooled to a temporary file, removed on close.
+type compressedBody struct {
+	*os.File
+}
+
+func (b *compressedBody) Close() error {
+	err := b.File.Close()
+	os.Remove(b.File.Name())
+	return err
+}
+
+// Compresses an upload body if the client has a compression codec.
+// Returns the body to send, its metadata and its size. The metadata is copied before being modified.
+//
+// The codec fields are only set on encoded bodies. Since they'd make raw bodies be decoded on download,
+// uploading them without compression is an error.
+func (c *Client) compressBody(body io.ReadCloser, meta payload.BlobMeta, size uint64) (io.ReadCloser, payload.BlobMeta, uint64, error) {
+	if c.compression == nil || body == nil {
+		for _, field := range []string{CodecField, OriginalSizeField} {
+			if _, ok := meta.Fields[field]; ok {
+				if body != nil {
+					body.Close()
+				}
+				return nil, meta, 0, fmt.Errorf("metadata field '%s' is reserved for compressed uploads", field)
+			}
+		}
+		return body, meta, size, nil
+	}
+
+	meta = CopyMeta(meta)
+	removeCodecFields(meta)
+	defer body.Close()
+
+	file, err := ioutil.TempFile("", "menmos-compress-*")
+	if err != nil {
+		return nil, meta, 0, errors.Wrap(err, "failed to create compression spool file")
+	}
+	compressed := &compressedBody{File: file}
+
+	originalSize, err := compressTo(c.compression, compressed, body)
+	if err != nil {
+		compressed.Close()
+		return nil, meta, 0, errors.Wrapf(err, "failed to compress body with '%s'", c.compression.Name())
+	}
+
+	compressedSize, err := compressed.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = compressed.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		compressed.Close()
+		return nil, meta, 0, errors.Wrap(err, "failed to rewind compressed body")
+	}
+
+	meta.Fields[CodecField] = c.compression.Name()
+	meta.Fields[OriginalSizeField] = strconv.FormatInt(originalSize, 10)
+
+	return compressed, meta, uint64(compressedSize), nil
+}
+
+func compressTo(codec Codec, dst io.Writer, src io.Reader) (int64, error) {
+	writer, err := codec.NewWriter(dst)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(writer, src)
+	if err != nil {
+		writer.Close()
+		return n, err
+	}
+
+	return n, writer.Close()
+}
+
+// decodedBody is a decompressed blob body, closing both the decoder and the underlying body.
+type decodedBody struct {
+	io.Reader
+	decoder io.Closer
+	body    io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	b.decoder.Close()
+	return b.body.Close()
+}
+
+// Wraps a blob body in a decoder, optionally restricted to a range of the decoded content.
+// Encoded bodies can't be read from an arbitrary offset, so ranges are read by decoding from the start.
+func decodeBody(codec Codec, blobID string, body io.ReadCloser, readRange *Range) (io.ReadCloser, error) {
+	decoder, err := codec.NewReader(body)
+	if err != nil {
+		body.Close()
+		return nil, errors.Wrapf(err, "failed to decode blob '%s' with '%s'", blobID, codec.Name())
+	}
+
+	decoded := &decodedBody{Reader: decoder, decoder: decoder, body: body}
+	if readRange == nil {
+		return decoded, nil
+	}
+
+	if readRange.Start > readRange.End || readRange.Start < 0 {
+		decoded.Close()
+		return nil, fmt.Errorf("invalid range for read request: %d-%d", readRange.Start, readRange.End)
+	}
+
+	if _, err := io.CopyN(ioutil.Discard, decoder, readRange.Start); err != nil && err != io.EOF {
+		decoded.Close()
+		return nil, errors.Wrapf(err, "failed to decode blob '%s' with '%s'", blobID, codec.Name())
+	}
+	decoded.Reader = io.LimitReader(decoder, readRange.End-readRange.Start+1)
+
+	return decoded, nil
+}
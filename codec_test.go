@@ -0,0 +1,123 @@
+package menmos_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/payload"
+	"github.com/pkg/errors"
+)
+
+func Test_Compression(t *testing.T) {
+	plain, server := newTestClient(t)
+	client, err := menmos.New(server.URL, "admin", "password", menmos.WithCompression(menmos.Gzip), menmos.WithChecksums())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	content := strings.Repeat("a fairly compressible log line\n", 200)
+
+	seekableID, err := client.CreateBlob(ioutil.NopCloser(strings.NewReader(content)), payload.NewBlobMeta(), uint64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamedID, err := client.CreateBlob(streamOnly{strings.NewReader(content)}, payload.NewBlobMeta(), uint64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{seekableID, streamedID} {
+		blob, _ := server.Blob(id)
+		if len(blob.Data) >= len(content) {
+			t.Errorf("%s: body wasn't compressed (%d bytes)", id, len(blob.Data))
+		}
+		if blob.Meta.Fields[menmos.CodecField] != "gzip" || blob.Meta.Fields[menmos.OriginalSizeField] != "6200" {
+			t.Errorf("%s: unexpected codec fields: %+v", id, blob.Meta.Fields)
+		}
+		if !strings.HasPrefix(blob.Meta.Fields[menmos.ChecksumField], "sha256:") {
+			t.Errorf("%s: missing checksum: %+v", id, blob.Meta.Fields)
+		}
+
+		body, err := client.GetBodyContext(ctx, id, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(body)
+		body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("%s: body wasn't decompressed", id)
+		}
+
+		body, err = client.GetBodyContext(ctx, id, &menmos.Range{Start: 31, End: 35})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ = ioutil.ReadAll(body)
+		body.Close()
+		if string(data) != "a fai" {
+			t.Errorf("%s: unexpected range %q", id, data)
+		}
+
+		if err := client.Verify(ctx, id); err != nil {
+			t.Errorf("%s: %v", id, err)
+		}
+	}
+
+	// Gzip bodies are decompressed by default.
+	body, err := plain.GetBody(seekableID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(body)
+	body.Close()
+	if string(data) != content {
+		t.Error("body wasn't decompressed by default")
+	}
+
+	raw, err := menmos.New(server.URL, "admin", "password", menmos.WithoutDecompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err = raw.GetBody(seekableID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = ioutil.ReadAll(body)
+	body.Close()
+	if string(data) == content {
+		t.Error("body was decompressed without decompression")
+	}
+	if err := raw.Verify(ctx, seekableID); errors.Cause(err) != menmos.ErrUnknownCodec {
+		t.Errorf("expected ErrUnknownCodec, got %v", err)
+	}
+
+	// Uploading the codec fields without compression would make the raw body be decoded.
+	meta, err := plain.GetMetadata(seekableID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.UpdateBlob(seekableID, ioutil.NopCloser(bytes.NewReader([]byte("raw"))), meta, 3); err == nil {
+		t.Fatal("expected the reserved codec fields to be rejected")
+	}
+
+	custom := payload.NewBlobMeta()
+	custom.Fields["codec"] = "mine"
+	if err := plain.UpdateBlob(seekableID, ioutil.NopCloser(bytes.NewReader([]byte("raw"))), custom, 3); err != nil {
+		t.Fatal(err)
+	}
+	meta, err = plain.GetMetadata(seekableID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := meta.Fields[menmos.CodecField]; ok || meta.Fields["codec"] != "mine" {
+		t.Errorf("unexpected fields after a raw update: %+v", meta.Fields)
+	}
+}
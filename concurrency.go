@@ -0,0 +1,56 @@
+package menmos
+
+import (
+	"context"
+	"sync"
+)
+
+const defaultConcurrency = 4
+
+// Runs fn for every index in [0, count) using at most `concurrency` goroutines.
+// The first error cancels the context passed to the remaining calls and is returned.
+func runConcurrently(ctx context.Context, concurrency int, count int, fn func(ctx context.Context, i int) error) error {
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	indices := make(chan int)
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+
+	for w := 0; w < concurrency && w < count; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				if err := fn(ctx, i); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < count; i++ {
+		select {
+		case indices <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indices)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
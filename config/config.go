@@ -1,7 +1,9 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
 
@@ -14,14 +16,39 @@ const menmosConfigFileName = "client.toml"
 
 // A Config represents the on-disk configuration of a menmos client.
 type Config struct {
-	Profiles map[string]Profile `json:"profiles,omitempty"`
+	// DefaultProfile is the profile used when no profile name is specified.
+	DefaultProfile string             `json:"default_profile,omitempty"`
+	Profiles       map[string]Profile `json:"profiles,omitempty"`
 }
 
-func loadConfigFromFile(path string) (*Config, error) {
+// GetProfile returns the profile with the specified name.
+// If the name is empty, the default profile is returned.
+func (c *Config) GetProfile(profileName string) (*Profile, error) {
+	if profileName == "" {
+		if c.DefaultProfile == "" {
+			return nil, errors.New("no profile specified and no default profile configured")
+		}
+		profileName = c.DefaultProfile
+	}
+
+	if profile, ok := c.Profiles[profileName]; ok {
+		return &profile, nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("profile '%s' not found", profileName))
+}
+
+// LoadFromFile loads a config from the specified path.
+// A missing file is treated as an empty configuration.
+func LoadFromFile(path string) (*Config, error) {
 	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &Config{Profiles: make(map[string]Profile)}, nil
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to open menmos configuration file")
 	}
+	defer file.Close()
 
 	decoder := toml.NewDecoder(file).SetTagName("json")
 
@@ -32,10 +59,56 @@ func loadConfigFromFile(path string) (*Config, error) {
 		err = errors.Wrap(err, "failed to decode TOML config")
 	}
 
+	if cfg.Profiles == nil {
+		cfg.Profiles = make(map[string]Profile)
+	}
+
 	return &cfg, err
 }
 
-func getDefaultConfigPath() (string, error) {
+// Save writes the config to the specified path, creating its parent directory if needed.
+// The file is only readable by the current user since profiles can contain secrets.
+func (c *Config) Save(configPath string) error {
+	if err := os.MkdirAll(path.Dir(configPath), 0700); err != nil {
+		return errors.Wrap(err, "failed to create menmos config directory")
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).SetTagName("json").Encode(c); err != nil {
+		return errors.Wrap(err, "failed to encode TOML config")
+	}
+
+	if err := ioutil.WriteFile(configPath, buf.Bytes(), 0600); err != nil {
+		return errors.Wrap(err, "failed to write menmos configuration file")
+	}
+
+	return nil
+}
+
+// SetProfile adds or replaces a profile.
+func (c *Config) SetProfile(profileName string, profile Profile) {
+	if c.Profiles == nil {
+		c.Profiles = make(map[string]Profile)
+	}
+	c.Profiles[profileName] = profile
+}
+
+// RemoveProfile removes a profile, unsetting the default profile if it pointed to it.
+func (c *Config) RemoveProfile(profileName string) error {
+	if _, ok := c.Profiles[profileName]; !ok {
+		return errors.New(fmt.Sprintf("profile '%s' not found", profileName))
+	}
+
+	delete(c.Profiles, profileName)
+	if c.DefaultProfile == profileName {
+		c.DefaultProfile = ""
+	}
+
+	return nil
+}
+
+// DefaultPath returns the path of the default menmos client configuration file.
+func DefaultPath() (string, error) {
 	configPath, err := os.UserConfigDir()
 	if err != nil {
 		return "", errors.Wrap(err, "failed to get the user configuration directory")
@@ -47,32 +120,38 @@ func getDefaultConfigPath() (string, error) {
 	return menmosConfigPath, nil
 }
 
-// LoadDefault loads a config from the default path.
+// LoadOrCreateDefault loads a config from the default path.
 func LoadOrCreateDefault() (*Config, error) {
-	configPath, err := getDefaultConfigPath()
+	configPath, err := DefaultPath()
 	if err != nil {
 		return nil, err
 	}
 
 	configDir := path.Dir(configPath)
-	if err := os.MkdirAll(configDir, 0644); err != nil {
+	if err := os.MkdirAll(configDir, 0700); err != nil {
 		return nil, errors.Wrap(err, "failed to create menmos config directory")
 	}
 
-	config, err := loadConfigFromFile(configPath)
+	config, err := LoadFromFile(configPath)
 	return config, err
 }
 
+// SaveDefault writes the config to the default path.
+func (c *Config) SaveDefault() error {
+	configPath, err := DefaultPath()
+	if err != nil {
+		return err
+	}
+	return c.Save(configPath)
+}
+
 // LoadProfileFromDefaultConfig is a utility method for loading a single profile from the default config location.
+// If the profile name is empty, the default profile is loaded.
 func LoadProfileFromDefaultConfig(profileName string) (*Profile, error) {
 	config, err := LoadOrCreateDefault()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to read profile from configuration")
 	}
 
-	if profile, ok := config.Profiles[profileName]; ok {
-		return &profile, nil
-	}
-
-	return nil, errors.New(fmt.Sprintf("profile '%s' not found", profileName))
+	return config.GetProfile(profileName)
 }
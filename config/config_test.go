@@ -0,0 +1,168 @@
+package config_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/menmos/menmos-go/config"
+)
+
+func Test_SaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "menmos-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	configPath := filepath.Join(dir, "menmos", "client.toml")
+
+	cfg, err := config.LoadFromFile(configPath)
+	if err != nil {
+		t.Fatalf("loading a missing config should not fail: %v", err)
+	}
+
+	retryCount := uint32(2)
+	cfg.SetProfile("prod", config.Profile{Host: "https://menmos.example.com", Username: "admin", PasswordEnv: "MENMOS_PASSWORD", RetryCount: &retryCount})
+	cfg.SetProfile("dev", config.Profile{Host: "http://localhost:3030", Token: "abc"})
+	cfg.DefaultProfile = "prod"
+
+	if err := cfg.Save(configPath); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := config.LoadFromFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := loaded.GetProfile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if profile.Host != "https://menmos.example.com" || profile.PasswordEnv != "MENMOS_PASSWORD" {
+		t.Errorf("unexpected default profile: %+v", profile)
+	}
+
+	if profile.RetryCount == nil || *profile.RetryCount != 2 {
+		t.Errorf("expected retry count to be preserved, got %v", profile.RetryCount)
+	}
+
+	if err := loaded.RemoveProfile("prod"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loaded.GetProfile(""); err == nil {
+		t.Error("expected an error once the default profile is removed")
+	}
+
+	if _, err := loaded.GetProfile("dev"); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_GetProfile(t *testing.T) {
+	cfg := config.Config{
+		DefaultProfile: "prod",
+		Profiles: map[string]config.Profile{
+			"prod": {Host: "https://menmos.example.com"},
+			"dev":  {Host: "http://localhost:3030"},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		defaultProfile string
+		profileName    string
+		expectedHost   string
+		wantErr        bool
+	}{
+		{"named profile", "prod", "dev", "http://localhost:3030", false},
+		{"default profile", "prod", "", "https://menmos.example.com", false},
+		{"named profile over default", "prod", "prod", "https://menmos.example.com", false},
+		{"missing profile", "prod", "staging", "", true},
+		{"no default profile", "", "", "", true},
+		{"missing default profile", "staging", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.DefaultProfile = tt.defaultProfile
+
+			profile, err := cfg.GetProfile(tt.profileName)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", profile)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if profile.Host != tt.expectedHost {
+				t.Errorf("expected host '%s', got '%s'", tt.expectedHost, profile.Host)
+			}
+		})
+	}
+}
+
+func Test_LoadFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "menmos-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name             string
+		content          *string
+		expectedProfiles int
+		wantErr          bool
+	}{
+		{"missing file", nil, 0, false},
+		{"empty file", stringPtr(""), 0, false},
+		{"default profile only", stringPtr("default_profile = \"prod\"\n"), 0, false},
+		{"profiles", stringPtr("[profiles.prod]\nhost = \"https://menmos.example.com\"\n"), 1, false},
+		{"invalid TOML", stringPtr("profiles = ["), 0, true},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(dir, fmt.Sprintf("client-%d.toml", i))
+			if tt.content != nil {
+				if err := ioutil.WriteFile(configPath, []byte(*tt.content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg, err := config.LoadFromFile(configPath)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Profiles == nil {
+				t.Fatal("expected an initialized profile map")
+			}
+			if len(cfg.Profiles) != tt.expectedProfiles {
+				t.Errorf("expected %d profiles, got %d", tt.expectedProfiles, len(cfg.Profiles))
+			}
+			if tt.content == nil {
+				if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+					t.Error("loading a missing config should not create it")
+				}
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -1,8 +1,171 @@
 package config
 
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
 // A Profile contains all information for connecting to a menmos cluster.
+//
+// Secrets can either be stored inline (Password, Token) or referenced indirectly
+// through a command, a file or an environment variable so that the configuration
+// file itself never contains plaintext credentials.
 type Profile struct {
 	Host     string `json:"host,omitempty"`
 	Username string `json:"username,omitempty"`
 	Password string `json:"password,omitempty"`
+
+	// PasswordCommand is a shell command whose standard output is the password.
+	PasswordCommand string `json:"password_command,omitempty"`
+
+	// PasswordFile is the path of a file containing the password.
+	PasswordFile string `json:"password_file,omitempty"`
+
+	// PasswordEnv is the name of an environment variable containing the password.
+	PasswordEnv string `json:"password_env,omitempty"`
+
+	// Token is a pre-issued bearer token. Token profiles skip authentication entirely.
+	Token string `json:"token,omitempty"`
+
+	// TokenFile is the path of a file containing a pre-issued bearer token.
+	TokenFile string `json:"token_file,omitempty"`
+
+	// Timeout is how long to wait for a server to connect and send response headers, as a duration string
+	// (e.g. "30s"). Streaming a body isn't bounded by it.
+	Timeout string `json:"timeout,omitempty"`
+
+	// RetryCount is how many times an idempotent request is retried on transient failures.
+	RetryCount *uint32 `json:"retry_count,omitempty"`
+
+	// CAFile is the path of a PEM bundle of additional trusted certificate authorities.
+	CAFile string `json:"ca_file,omitempty"`
+
+	// TLSCertFile is the path of the PEM client certificate used for mutual TLS.
+	TLSCertFile string `json:"tls_cert_file,omitempty"`
+
+	// TLSKeyFile is the path of the PEM private key matching TLSCertFile.
+	TLSKeyFile string `json:"tls_key_file,omitempty"`
+
+	// TLSServerName overrides the server name used to verify server certificates.
+	TLSServerName string `json:"tls_server_name,omitempty"`
+
+	// InsecureSkipVerify disables the verification of server certificates.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+
+	// ProxyURL is the URL of a proxy through which all requests are sent.
+	ProxyURL string `json:"proxy_url,omitempty"`
+
+	// UserAgentSuffix is appended to the user agent of every request.
+	UserAgentSuffix string `json:"user_agent_suffix,omitempty"`
+}
+
+// UsesToken returns whether the profile authenticates with a pre-issued token instead of a username and password.
+func (p *Profile) UsesToken() bool {
+	return p.Token != "" || p.TokenFile != ""
+}
+
+// ResolveToken returns the bearer token of a token profile.
+func (p *Profile) ResolveToken() (string, error) {
+	if p.Token != "" && p.TokenFile != "" {
+		return "", errors.New("profile cannot specify both 'token' and 'token_file'")
+	}
+
+	if p.Token != "" {
+		return p.Token, nil
+	}
+
+	if p.TokenFile != "" {
+		token, err := readSecretFile(p.TokenFile)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to read token file")
+		}
+		return token, nil
+	}
+
+	return "", errors.New("profile has no token")
+}
+
+// ResolvePassword returns the profile password, resolving any indirection.
+func (p *Profile) ResolvePassword() (string, error) {
+	sourceCount := 0
+	for _, source := range []string{p.Password, p.PasswordCommand, p.PasswordFile, p.PasswordEnv} {
+		if source != "" {
+			sourceCount++
+		}
+	}
+
+	if sourceCount > 1 {
+		return "", errors.New("profile must specify at most one of 'password', 'password_command', 'password_file' and 'password_env'")
+	}
+
+	switch {
+	case p.PasswordCommand != "":
+		password, err := runSecretCommand(p.PasswordCommand)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to run password command")
+		}
+		return password, nil
+	case p.PasswordFile != "":
+		password, err := readSecretFile(p.PasswordFile)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to read password file")
+		}
+		return password, nil
+	case p.PasswordEnv != "":
+		password, ok := os.LookupEnv(p.PasswordEnv)
+		if !ok {
+			return "", fmt.Errorf("environment variable '%s' is not set", p.PasswordEnv)
+		}
+		return password, nil
+	}
+
+	return p.Password, nil
+}
+
+// Secrets are often written with a trailing newline by editors and password managers, we don't want it.
+func trimSecret(secret string) string {
+	return strings.TrimRight(secret, "\r\n")
+}
+
+func readSecretFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(expandHome(path))
+	if err != nil {
+		return "", err
+	}
+	return trimSecret(string(data)), nil
+}
+
+func runSecretCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return trimSecret(string(out)), nil
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return home + strings.TrimPrefix(path, "~")
 }
@@ -0,0 +1,93 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/menmos/menmos-go/config"
+)
+
+func Test_ResolvePassword(t *testing.T) {
+	dir, err := ioutil.TempDir("", "menmos-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	passwordFile := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(passwordFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("MENMOS_GO_TEST_PASSWORD", "from-env")
+	defer os.Unsetenv("MENMOS_GO_TEST_PASSWORD")
+
+	type testCase struct {
+		name     string
+		profile  config.Profile
+		expected string
+		wantErr  bool
+	}
+
+	cases := []testCase{
+		{"plaintext", config.Profile{Password: "plain"}, "plain", false},
+		{"file", config.Profile{PasswordFile: passwordFile}, "from-file", false},
+		{"env", config.Profile{PasswordEnv: "MENMOS_GO_TEST_PASSWORD"}, "from-env", false},
+		{"missing env", config.Profile{PasswordEnv: "MENMOS_GO_TEST_MISSING"}, "", true},
+		{"missing file", config.Profile{PasswordFile: filepath.Join(dir, "nope")}, "", true},
+		{"multiple sources", config.Profile{Password: "plain", PasswordEnv: "MENMOS_GO_TEST_PASSWORD"}, "", true},
+	}
+
+	if runtime.GOOS != "windows" {
+		cases = append(cases, testCase{"command", config.Profile{PasswordCommand: "echo from-command"}, "from-command", false})
+		cases = append(cases, testCase{"failing command", config.Profile{PasswordCommand: "exit 1"}, "", true})
+	}
+
+	for _, tCase := range cases {
+		t.Run(tCase.name, func(t *testing.T) {
+			actual, err := tCase.profile.ResolvePassword()
+			if (err != nil) != tCase.wantErr {
+				t.Errorf("expectedErr=%v, gotErr=%v", tCase.wantErr, err)
+				return
+			}
+
+			if actual != tCase.expected {
+				t.Errorf("expected password=%q, got %q", tCase.expected, actual)
+			}
+		})
+	}
+}
+
+func Test_ResolveToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "menmos-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("abc123\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	profile := config.Profile{TokenFile: tokenFile}
+	if !profile.UsesToken() {
+		t.Fatal("expected profile to use a token")
+	}
+
+	token, err := profile.ResolveToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "abc123" {
+		t.Errorf("expected token=%q, got %q", "abc123", token)
+	}
+
+	profile.Token = "inline"
+	if _, err := profile.ResolveToken(); err == nil {
+		t.Error("expected an error when both token and token_file are set")
+	}
+}
@@ -0,0 +1,191 @@
+package menmos
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/menmos/menmos-go/payload"
+	"github.com/pkg/errors"
+)
+
+// keyedMutex serializes work per key, e.g. uploads of the same content.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	mu      sync.Mutex
+	waiters int
+}
+
+func (m *keyedMutex) lock(key string) func() {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = make(map[string]*keyedLock)
+	}
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		m.locks[key] = l
+	}
+	l.waiters++
+	m.mu.Unlock()
+
+	l.mu.Lock()
+
+	return func() {
+		l.mu.Unlock()
+
+		m.mu.Lock()
+		l.waiters--
+		if l.waiters == 0 {
+			delete(m.locks, key)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// Copies a body to a temporary file while hashing it.
+// The caller is responsible for closing and removing the file.
+func spoolToTempFile(r io.Reader) (*os.File, string, uint64, error) {
+	file, err := ioutil.TempFile("", "menmos-spool-*")
+	if err != nil {
+		return nil, "", 0, errors.Wrap(err, "failed to create spool file")
+	}
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, h), r)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, "", 0, errors.Wrap(err, "failed to spool body")
+	}
+
+	return file, formatChecksum(ChecksumSHA256, h), uint64(size), nil
+}
+
+// Merges the tags and fields of `extra` into `meta`.
+// Returns the merged metadata and whether it differs from `meta`.
+func mergeMeta(meta payload.BlobMeta, extra payload.BlobMeta) (payload.BlobMeta, bool) {
+	merged := CopyMeta(meta)
+	changed := false
+
+	for k, v := range extra.Fields {
+		if current, ok := merged.Fields[k]; !ok || current != v {
+			merged.Fields[k] = v
+			changed = true
+		}
+	}
+
+	for _, tag := range extra.Tags {
+		if !hasTag(merged, tag) {
+			merged.Tags = append(merged.Tags, tag)
+			changed = true
+		}
+	}
+
+	return merged, changed
+}
+
+func hasTag(meta payload.BlobMeta, tag string) bool {
+	for _, t := range meta.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the blobs whose content has the provided checksum, sorted by ID.
+func (c *Client) blobsWithChecksum(ctx context.Context, checksum string) ([]payload.Hit, error) {
+	hits, err := c.QueryAll(ctx, payload.NewExpression().AndKeyValue(ChecksumField, checksum))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].ID < hits[j].ID })
+	return hits, nil
+}
+
+// Merges the metadata of an upload into an existing blob, skipping the update if nothing changes.
+func (c *Client) mergeInto(ctx context.Context, hit payload.Hit, meta payload.BlobMeta) error {
+	merged, changed := mergeMeta(hit.Metadata, meta)
+	if !changed {
+		return nil
+	}
+	return c.UpdateMetaContext(ctx, hit.ID, merged)
+}
+
+// PutDedup stores a body unless a blob with the same content already exists in the cluster.
+//
+// The content is identified by its SHA-256 checksum, stored in the ChecksumField field.
+// If a blob with the same checksum exists, the tags and fields of meta are merged into its metadata
+// and its ID is returned with created set to false. Otherwise, a new blob is created.
+//
+// The body is spooled to a temporary file to be hashed before the upload.
+// Uploads of the same content are serialized within a client. Menmos has no conditional create, so
+// across clients the protection is best-effort: after creating its blob, an uploader looks for duplicates
+// again and, if a blob with a lower ID exists, merges its metadata there and deletes its own copy.
+func (c *Client) PutDedup(ctx context.Context, r io.Reader, meta payload.BlobMeta) (id string, created bool, err error) {
+	ctx, finish := c.startOperation(ctx, OpPutDedup)
+	defer finish(&err)
+
+	file, checksum, size, err := spoolToTempFile(r)
+	if err != nil {
+		return "", false, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	unlock := c.dedupLocks.lock(checksum)
+	defer unlock()
+
+	existing, err := c.blobsWithChecksum(ctx, checksum)
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to look up duplicates")
+	}
+
+	if len(existing) > 0 {
+		if err := c.mergeInto(ctx, existing[0], meta); err != nil {
+			return "", false, errors.Wrapf(err, "failed to merge metadata into blob '%s'", existing[0].ID)
+		}
+		return existing[0].ID, false, nil
+	}
+
+	meta = CopyMeta(meta)
+	meta.Fields[ChecksumField] = checksum
+
+	id, err = c.pushInternal(ctx, "", file, meta, size, uploadOptions{})
+	if err != nil {
+		return "", false, err
+	}
+
+	// Another uploader may have created the same content concurrently.
+	duplicates, err := c.blobsWithChecksum(ctx, checksum)
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to look up duplicates")
+	}
+
+	if len(duplicates) == 0 || duplicates[0].ID == id {
+		return id, true, nil
+	}
+
+	winner := duplicates[0]
+	if err := c.mergeInto(ctx, winner, meta); err != nil {
+		return "", false, errors.Wrapf(err, "failed to merge metadata into blob '%s'", winner.ID)
+	}
+
+	if err := c.DeleteContext(ctx, id); err != nil {
+		return "", false, errors.Wrapf(err, "failed to delete duplicate blob '%s'", id)
+	}
+
+	return winner.ID, false, nil
+}
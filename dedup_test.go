@@ -0,0 +1,99 @@
+package menmos_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/payload"
+)
+
+func Test_PutDedup(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	firstMeta := payload.NewBlobMeta()
+	firstMeta.Tags = []string{"build"}
+	firstMeta.Fields["arch"] = "amd64"
+
+	firstID, created, err := client.PutDedup(ctx, strings.NewReader("artifact"), firstMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Fatal("expected the first upload to create a blob")
+	}
+
+	secondMeta := payload.NewBlobMeta()
+	secondMeta.Tags = []string{"release"}
+	secondMeta.Fields["version"] = "1.0.0"
+
+	secondID, created, err := client.PutDedup(ctx, strings.NewReader("artifact"), secondMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || secondID != firstID {
+		t.Fatalf("expected upload to reuse %s, got %s (created=%v)", firstID, secondID, created)
+	}
+
+	blob, _ := server.Blob(firstID)
+	if string(blob.Data) != "artifact" {
+		t.Errorf("unexpected data %q", blob.Data)
+	}
+	if len(blob.Meta.Tags) != 2 || blob.Meta.Fields["arch"] != "amd64" || blob.Meta.Fields["version"] != "1.0.0" {
+		t.Errorf("metadata wasn't merged: %+v", blob.Meta)
+	}
+	if !strings.HasPrefix(blob.Meta.Fields[menmos.ChecksumField], "sha256:") {
+		t.Errorf("missing checksum field: %+v", blob.Meta)
+	}
+
+	if _, created, err := client.PutDedup(ctx, strings.NewReader("other"), payload.NewBlobMeta()); err != nil || !created {
+		t.Fatalf("expected different content to create a blob: created=%v err=%v", created, err)
+	}
+	if server.BlobCount() != 2 {
+		t.Errorf("expected 2 blobs, got %d", server.BlobCount())
+	}
+}
+
+func Test_PutDedupConcurrent(t *testing.T) {
+	client, server := newTestClient(t)
+	other, err := menmos.New(server.URL, "admin", "password", menmos.WithMaxRetryCount(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	const uploads = 8
+	ids := make([]string, uploads)
+	errs := make([]error, uploads)
+
+	var wg sync.WaitGroup
+	for i := 0; i < uploads; i++ {
+		uploader := client
+		if i%2 == 1 {
+			uploader = other
+		}
+
+		wg.Add(1)
+		go func(i int, uploader *menmos.Client) {
+			defer wg.Done()
+			ids[i], _, errs[i] = uploader.PutDedup(ctx, strings.NewReader("same content"), payload.NewBlobMeta())
+		}(i, uploader)
+	}
+	wg.Wait()
+
+	for i := range ids {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if ids[i] != ids[0] {
+			t.Errorf("upload %d returned %s, expected %s", i, ids[i], ids[0])
+		}
+	}
+
+	if server.BlobCount() != 1 {
+		t.Errorf("expected a single blob, got %d", server.BlobCount())
+	}
+}
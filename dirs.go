@@ -0,0 +1,323 @@
+package menmos
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/menmos/menmos-go/payload"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultPathField is the metadata field storing the relative path of a blob uploaded from a directory.
+	DefaultPathField = "path"
+
+	// DefaultMTimeField is the metadata field storing the modification time of a blob uploaded from a directory.
+	DefaultMTimeField = "mtime"
+
+	// DefaultSizeField is the metadata field storing the size of a blob uploaded from a directory.
+	DefaultSizeField = "size"
+)
+
+// UploadDirOptions controls how a directory tree is mapped to blobs.
+type UploadDirOptions struct {
+	// Meta is the base metadata applied to every uploaded blob.
+	Meta payload.BlobMeta
+
+	// PathField is the field storing the slash-separated path of the file relative to the root.
+	// Defaults to DefaultPathField.
+	PathField string
+
+	// MTimeField is the field storing the modification time of the file (RFC 3339).
+	// The modification time is not recorded if empty.
+	MTimeField string
+
+	// SizeField is the field storing the size of the file in bytes.
+	// The size is not recorded if empty.
+	SizeField string
+
+	// ExtensionTags tags every blob with the extension of its file, without the leading dot.
+	ExtensionTags bool
+
+	// Filter, if set, is called for every regular file. Files for which it returns false are skipped.
+	Filter func(relPath string, info os.FileInfo) bool
+
+	// Concurrency is the maximum number of concurrent uploads.
+	Concurrency int
+}
+
+// DefaultUploadDirOptions returns upload options recording the path, modification time and size of every file.
+func DefaultUploadDirOptions() UploadDirOptions {
+	return UploadDirOptions{
+		Meta:        payload.NewBlobMeta(),
+		PathField:   DefaultPathField,
+		MTimeField:  DefaultMTimeField,
+		SizeField:   DefaultSizeField,
+		Concurrency: defaultConcurrency,
+	}
+}
+
+// DownloadOptions controls how query hits are mapped back to files.
+type DownloadOptions struct {
+	// PathField is the field storing the relative path of every blob.
+	// Defaults to DefaultPathField.
+	PathField string
+
+	// MTimeField, if set, is the field from which the modification time of downloaded files is restored.
+	MTimeField string
+
+	// Concurrency is the maximum number of concurrent downloads.
+	Concurrency int
+}
+
+// A TransferredFile associates a file relative to a directory root with its blob.
+type TransferredFile struct {
+	Path string
+	ID   string
+}
+
+type localFile struct {
+	relPath string
+	info    os.FileInfo
+}
+
+func pathFieldOrDefault(field string) string {
+	if field == "" {
+		return DefaultPathField
+	}
+	return field
+}
+
+// Lists the regular files under root, with slash-separated paths relative to root.
+func walkLocalFiles(root string, filter func(string, os.FileInfo) bool) ([]localFile, error) {
+	var files []localFile
+	err := filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if filter != nil && !filter(relPath, info) {
+			return nil
+		}
+
+		files = append(files, localFile{relPath: relPath, info: info})
+		return nil
+	})
+
+	return files, errors.Wrapf(err, "failed to walk '%s'", root)
+}
+
+func formatMTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func (o *UploadDirOptions) fileMeta(file localFile) payload.BlobMeta {
+	meta := CopyMeta(o.Meta)
+	meta.Fields[pathFieldOrDefault(o.PathField)] = file.relPath
+
+	if o.MTimeField != "" {
+		meta.Fields[o.MTimeField] = formatMTime(file.info.ModTime())
+	}
+
+	if o.SizeField != "" {
+		meta.Fields[o.SizeField] = fmt.Sprintf("%d", file.info.Size())
+	}
+
+	if o.ExtensionTags {
+		if ext := strings.TrimPrefix(path.Ext(file.relPath), "."); ext != "" {
+			meta.Tags = append(meta.Tags, ext)
+		}
+	}
+
+	return meta
+}
+
+// Uploads a single local file to a new blob, or over an existing blob if blobID is non-empty.
+func (c *Client) uploadFile(ctx context.Context, filePath string, blobID string, meta payload.BlobMeta) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	// The HTTP client closes the body once sent, but not if we fail before sending it.
+	defer file.Close()
+
+	// Stat the open file rather than trusting the walk, the file might have changed since.
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	if blobID == "" {
+		return c.CreateBlobContext(ctx, file, meta, uint64(info.Size()))
+	}
+
+	return blobID, c.UpdateBlobContext(ctx, blobID, file, meta, uint64(info.Size()))
+}
+
+// UploadDir walks a directory and creates a blob for every regular file it contains.
+// The uploaded files are returned sorted by path.
+func (c *Client) UploadDir(ctx context.Context, root string, opts UploadDirOptions) ([]TransferredFile, error) {
+	files, err := walkLocalFiles(root, opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	uploaded := make([]TransferredFile, len(files))
+	err = runConcurrently(ctx, opts.Concurrency, len(files), func(ctx context.Context, i int) error {
+		file := files[i]
+		id, err := c.uploadFile(ctx, filepath.Join(root, filepath.FromSlash(file.relPath)), "", opts.fileMeta(file))
+		if err != nil {
+			return errors.Wrapf(err, "failed to upload '%s'", file.relPath)
+		}
+
+		uploaded[i] = TransferredFile{Path: file.relPath, ID: id}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(uploaded, func(i, j int) bool { return uploaded[i].Path < uploaded[j].Path })
+	return uploaded, nil
+}
+
+// Resolves a slash-separated relative path stored in metadata to a local path under root.
+// Paths escaping the root are rejected, as are backslashes and volume names, which escape it on Windows.
+func localPath(root string, relPath string) (string, error) {
+	invalid := fmt.Errorf("invalid relative path '%s'", relPath)
+
+	cleaned := path.Clean(relPath)
+	if relPath == "" || path.IsAbs(relPath) || strings.Contains(relPath, `\`) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", invalid
+	}
+
+	native := filepath.FromSlash(cleaned)
+	if filepath.IsAbs(native) || filepath.VolumeName(native) != "" {
+		return "", invalid
+	}
+
+	joined := filepath.Join(root, native)
+	if rel, err := filepath.Rel(filepath.Join(root, "."), joined); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", invalid
+	}
+
+	return joined, nil
+}
+
+// Downloads a blob to a local file atomically, restoring its modification time if provided.
+func (c *Client) downloadFile(ctx context.Context, blobID string, destination string, mtime *time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+		return err
+	}
+
+	body, err := c.GetBodyContext(ctx, blobID, nil)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(destination), ".menmos-download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := io.Copy(tmpFile, body); err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	// Temporary files are only readable by their owner, downloads get the permissions of a regular file.
+	if err := os.Chmod(tmpFile.Name(), 0644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpFile.Name(), destination); err != nil {
+		return err
+	}
+
+	if mtime != nil {
+		return os.Chtimes(destination, *mtime, *mtime)
+	}
+
+	return nil
+}
+
+// DownloadQuery recreates a directory tree under destRoot from the blobs matching an expression.
+// Every blob is written to the path stored in its path field; blobs without that field are skipped.
+// The downloaded files are returned sorted by path.
+//
+// Nothing is downloaded if the path of a blob is invalid or escapes destRoot, or if several blobs share a path.
+func (c *Client) DownloadQuery(ctx context.Context, expr payload.Expression, destRoot string, opts DownloadOptions) ([]TransferredFile, error) {
+	pathField := pathFieldOrDefault(opts.PathField)
+
+	hits, err := c.QueryAll(ctx, expr.AndHasKey(pathField))
+	if err != nil {
+		return nil, err
+	}
+
+	destinations := make([]string, len(hits))
+	owners := make(map[string]string, len(hits))
+	for i, hit := range hits {
+		destination, err := localPath(destRoot, hit.Metadata.Fields[pathField])
+		if err != nil {
+			return nil, errors.Wrapf(err, "blob '%s'", hit.ID)
+		}
+		if owner, ok := owners[destination]; ok {
+			return nil, fmt.Errorf("blobs '%s' and '%s' are both downloaded to '%s'", owner, hit.ID, destination)
+		}
+		owners[destination] = hit.ID
+		destinations[i] = destination
+	}
+
+	downloaded := make([]TransferredFile, len(hits))
+	err = runConcurrently(ctx, opts.Concurrency, len(hits), func(ctx context.Context, i int) error {
+		hit := hits[i]
+		relPath := hit.Metadata.Fields[pathField]
+		destination := destinations[i]
+
+		var mtime *time.Time
+		if opts.MTimeField != "" {
+			if raw, ok := hit.Metadata.Fields[opts.MTimeField]; ok {
+				if parsed, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+					mtime = &parsed
+				}
+			}
+		}
+
+		if err := c.downloadFile(ctx, hit.ID, destination, mtime); err != nil {
+			return errors.Wrapf(err, "failed to download '%s'", relPath)
+		}
+
+		downloaded[i] = TransferredFile{Path: relPath, ID: hit.ID}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(downloaded, func(i, j int) bool { return downloaded[i].Path < downloaded[j].Path })
+	return downloaded, nil
+}
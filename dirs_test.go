@@ -0,0 +1,173 @@
+package menmos_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/internal/menmostest"
+	"github.com/menmos/menmos-go/payload"
+)
+
+func newTestClient(t *testing.T) (*menmos.Client, *menmostest.Server) {
+	server := menmostest.NewServer()
+	t.Cleanup(server.Close)
+
+	client, err := menmos.New(server.URL, "admin", "password", menmos.WithMaxRetryCount(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client, server
+}
+
+func writeTestTree(t *testing.T, root string, files map[string]string) {
+	for relPath, content := range files {
+		filePath := filepath.Join(root, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "menmos-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func Test_UploadDirAndDownloadQuery(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	files := map[string]string{
+		"README.md":        "# readme",
+		"src/main.go":      "package main",
+		"src/util/util.go": "package util",
+	}
+
+	src := tempDir(t)
+	writeTestTree(t, src, files)
+
+	mtime := time.Date(2020, 5, 17, 12, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(src, "README.md"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := menmos.DefaultUploadDirOptions()
+	opts.Meta.Tags = append(opts.Meta.Tags, "mirror")
+	opts.ExtensionTags = true
+
+	uploaded, err := client.UploadDir(ctx, src, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(uploaded) != len(files) || server.BlobCount() != len(files) {
+		t.Fatalf("expected %d uploaded files, got %d (%d blobs)", len(files), len(uploaded), server.BlobCount())
+	}
+
+	blob, _ := server.Blob(uploaded[1].ID)
+	if uploaded[1].Path != "src/main.go" || blob.Meta.Fields["path"] != "src/main.go" || blob.Meta.Fields["size"] != "12" {
+		t.Errorf("unexpected metadata for %s: %+v", uploaded[1].Path, blob.Meta)
+	}
+
+	hasGoTag := false
+	for _, tag := range blob.Meta.Tags {
+		hasGoTag = hasGoTag || tag == "go"
+	}
+	if !hasGoTag {
+		t.Errorf("expected the extension tag on %+v", blob.Meta)
+	}
+
+	dst := tempDir(t)
+	downloaded, err := client.DownloadQuery(ctx, payload.NewExpression().AndTag("mirror"), dst, menmos.DownloadOptions{MTimeField: menmos.DefaultMTimeField})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(downloaded) != len(files) {
+		t.Fatalf("expected %d downloaded files, got %d", len(files), len(downloaded))
+	}
+
+	for relPath, content := range files {
+		data, err := ioutil.ReadFile(filepath.Join(dst, filepath.FromSlash(relPath)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("%s: expected %q, got %q", relPath, content, string(data))
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(dst, "README.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("expected mtime %s, got %s", mtime, info.ModTime())
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("expected a regular file mode, got %v", info.Mode())
+	}
+}
+
+func Test_DownloadQueryRejectsEscapingPaths(t *testing.T) {
+	tests := []string{"../escape.txt", "dir/../../escape.txt", "/etc/passwd", `..\..\escape.txt`, `dir\..\..\escape.txt`, `C:\escape.txt`}
+
+	for _, relPath := range tests {
+		t.Run(relPath, func(t *testing.T) {
+			client, server := newTestClient(t)
+
+			meta := payload.NewBlobMeta()
+			meta.Fields["path"] = relPath
+			server.Put([]byte("nope"), meta)
+
+			destRoot := filepath.Join(tempDir(t), "dest")
+			if _, err := client.DownloadQuery(context.Background(), payload.NewExpression(), destRoot, menmos.DownloadOptions{}); err == nil {
+				t.Fatal("expected a path escaping the destination to be rejected")
+			}
+			if _, err := os.Stat(filepath.Dir(destRoot)); err == nil {
+				entries, _ := ioutil.ReadDir(filepath.Dir(destRoot))
+				if len(entries) != 0 {
+					t.Errorf("expected nothing to be written, got %d entries", len(entries))
+				}
+			}
+		})
+	}
+}
+
+func Test_DownloadQueryRejectsDuplicatePaths(t *testing.T) {
+	client, server := newTestClient(t)
+
+	for _, relPath := range []string{"dir/file.txt", "other.txt", "dir/./file.txt"} {
+		meta := payload.NewBlobMeta()
+		meta.Fields["path"] = relPath
+		server.Put([]byte(relPath), meta)
+	}
+
+	destRoot := tempDir(t)
+	_, err := client.DownloadQuery(context.Background(), payload.NewExpression(), destRoot, menmos.DownloadOptions{})
+	if err == nil || !strings.Contains(err.Error(), "file.txt") {
+		t.Fatalf("expected blobs sharing a path to be rejected, got %v", err)
+	}
+
+	entries, err := ioutil.ReadDir(destRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected nothing to be downloaded, got %d entries", len(entries))
+	}
+}
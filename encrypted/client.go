@@ -0,0 +1,361 @@
+// Package encrypted provides client-side envelope encryption of blob bodies and sensitive metadata fields.
+//
+// Every blob is encrypted with its own random AES-256 data key. The data key is wrapped by a KeyProvider
+// and stored, with the other encryption parameters, in reserved metadata fields of the blob.
+// Bodies are encrypted with AES-GCM in fixed-size chunks, so a range of a blob can be read by fetching and
+// decrypting only the chunks covering it.
+//
+// Tags are never encrypted, since they must stay queryable. Encrypted fields can't be used in queries.
+package encrypted
+
+import (
+	"context"
+	"crypto/cipher"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/payload"
+	"github.com/pkg/errors"
+)
+
+const (
+	// AlgorithmField is the reserved field storing the encryption scheme of a blob.
+	AlgorithmField = "_enc_alg"
+
+	// KeyField is the reserved field storing the wrapped data key of a blob, base64-encoded.
+	KeyField = "_enc_key"
+
+	// KeyIDField is the reserved field storing the ID of the key encryption key that wrapped the data key.
+	KeyIDField = "_enc_key_id"
+
+	// ChunkSizeField is the reserved field storing the plaintext size of the chunks of a body.
+	ChunkSizeField = "_enc_chunk_size"
+
+	// SizeField is the reserved field storing the plaintext size of a body.
+	SizeField = "_enc_size"
+
+	// FieldsField is the reserved field storing the comma-separated names of the encrypted metadata fields.
+	FieldsField = "_enc_fields"
+
+	// Algorithm is the only supported encryption scheme.
+	Algorithm = "aes-256-gcm-chunked"
+
+	// DefaultChunkSize is the default plaintext size of the chunks of a body.
+	DefaultChunkSize = 64 * 1024
+)
+
+// ErrNotEncrypted is returned when reading a blob that wasn't encrypted by this package.
+var ErrNotEncrypted = errors.New("blob is not encrypted")
+
+// Options controls how blobs are encrypted.
+type Options struct {
+	// ChunkSize is the plaintext size of the chunks of new bodies. Zero means DefaultChunkSize.
+	// Smaller chunks make range reads cheaper, at the cost of 28 bytes of overhead per chunk.
+	ChunkSize int
+
+	// SensitiveFields are the metadata fields whose values are encrypted.
+	SensitiveFields []string
+}
+
+// Client wraps a menmos client to encrypt blobs before they are uploaded and decrypt them once downloaded.
+// Blob IDs, tags, sizes and non-sensitive fields are visible to the cluster.
+type Client struct {
+	client   *menmos.Client
+	provider KeyProvider
+	opts     Options
+}
+
+// New returns a client encrypting blobs with data keys wrapped by the provided key provider.
+func New(client *menmos.Client, provider KeyProvider, opts Options) (*Client, error) {
+	if provider == nil {
+		return nil, errors.New("key provider cannot be nil")
+	}
+	if opts.ChunkSize < 0 {
+		return nil, fmt.Errorf("invalid chunk size: %d", opts.ChunkSize)
+	}
+	if opts.ChunkSize == 0 {
+		opts.ChunkSize = DefaultChunkSize
+	}
+	for _, field := range opts.SensitiveFields {
+		if strings.HasPrefix(field, "_") || strings.Contains(field, ",") {
+			return nil, fmt.Errorf("field '%s' can't be encrypted", field)
+		}
+	}
+
+	return &Client{client: client, provider: provider, opts: opts}, nil
+}
+
+// The encryption parameters of a blob.
+type envelope struct {
+	aead      cipher.AEAD
+	chunkSize int64
+	size      int64
+}
+
+// Creates the envelope of a new body, recording its parameters in its metadata.
+func (c *Client) newEnvelope(ctx context.Context, meta payload.BlobMeta, size int64) (*envelope, error) {
+	dataKey, err := newDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	keyID, wrapped, err := c.provider.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to wrap data key")
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	meta.Fields[AlgorithmField] = Algorithm
+	meta.Fields[KeyField] = base64.StdEncoding.EncodeToString(wrapped)
+	meta.Fields[KeyIDField] = keyID
+	meta.Fields[ChunkSizeField] = strconv.Itoa(c.opts.ChunkSize)
+	meta.Fields[SizeField] = strconv.FormatInt(size, 10)
+
+	return &envelope{aead: aead, chunkSize: int64(c.opts.ChunkSize), size: size}, nil
+}
+
+// Opens the envelope of an existing blob from its metadata.
+func (c *Client) openEnvelope(ctx context.Context, blobID string, meta payload.BlobMeta) (*envelope, error) {
+	if meta.Fields[AlgorithmField] != Algorithm {
+		if _, ok := meta.Fields[AlgorithmField]; ok {
+			return nil, fmt.Errorf("blob '%s' uses unsupported encryption scheme '%s'", blobID, meta.Fields[AlgorithmField])
+		}
+		return nil, errors.Wrapf(ErrNotEncrypted, "blob '%s'", blobID)
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(meta.Fields[KeyField])
+	if err != nil {
+		return nil, errors.Wrapf(err, "blob '%s' has a malformed data key", blobID)
+	}
+
+	chunkSize, err := strconv.ParseInt(meta.Fields[ChunkSizeField], 10, 64)
+	if err != nil || chunkSize <= 0 {
+		return nil, fmt.Errorf("blob '%s' has a malformed chunk size", blobID)
+	}
+
+	size, err := strconv.ParseInt(meta.Fields[SizeField], 10, 64)
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("blob '%s' has a malformed size", blobID)
+	}
+
+	dataKey, err := c.provider.UnwrapKey(ctx, meta.Fields[KeyIDField], wrapped)
+	if err != nil {
+		return nil, errors.Wrapf(err, "blob '%s'", blobID)
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &envelope{aead: aead, chunkSize: chunkSize, size: size}, nil
+}
+
+func fieldAdditionalData(name string) []byte {
+	return []byte("field:" + name)
+}
+
+// Encrypts the sensitive fields of a metadata, which must hold the envelope parameters.
+func (c *Client) sealFields(env *envelope, meta payload.BlobMeta) error {
+	var sealedFields []string
+	for _, name := range c.opts.SensitiveFields {
+		value, ok := meta.Fields[name]
+		if !ok {
+			continue
+		}
+
+		sealed, err := seal(env.aead, []byte(value), fieldAdditionalData(name))
+		if err != nil {
+			return err
+		}
+		meta.Fields[name] = base64.StdEncoding.EncodeToString(sealed)
+		sealedFields = append(sealedFields, name)
+	}
+
+	delete(meta.Fields, FieldsField)
+	if len(sealedFields) > 0 {
+		sort.Strings(sealedFields)
+		meta.Fields[FieldsField] = strings.Join(sealedFields, ",")
+	}
+	return nil
+}
+
+// Decrypts the encrypted fields of a metadata in place.
+func openFields(blobID string, env *envelope, meta payload.BlobMeta) error {
+	if meta.Fields[FieldsField] == "" {
+		return nil
+	}
+
+	for _, name := range strings.Split(meta.Fields[FieldsField], ",") {
+		sealed, err := base64.StdEncoding.DecodeString(meta.Fields[name])
+		if err != nil {
+			return fmt.Errorf("blob '%s' has a malformed encrypted field '%s'", blobID, name)
+		}
+
+		value, err := open(env.aead, sealed, fieldAdditionalData(name))
+		if err != nil {
+			return errors.Wrapf(err, "failed to decrypt field '%s' of blob '%s'", name, blobID)
+		}
+		meta.Fields[name] = string(value)
+	}
+
+	delete(meta.Fields, FieldsField)
+	return nil
+}
+
+func (c *Client) encryptBody(ctx context.Context, body io.Reader, meta payload.BlobMeta, size uint64) (io.ReadCloser, payload.BlobMeta, uint64, error) {
+	meta = menmos.CopyMeta(meta)
+
+	env, err := c.newEnvelope(ctx, meta, int64(size))
+	if err != nil {
+		return nil, meta, 0, err
+	}
+
+	if err := c.sealFields(env, meta); err != nil {
+		return nil, meta, 0, err
+	}
+
+	if body == nil {
+		body = strings.NewReader("")
+	}
+	encrypted := ioutil.NopCloser(newEncryptingReader(body, env.aead, env.size, env.chunkSize))
+
+	return encrypted, meta, uint64(encryptedSize(env.size, env.chunkSize)), nil
+}
+
+// CreateBlob encrypts a body of the specified size and stores it in a new blob.
+// If the body is nil, the blob is created empty.
+func (c *Client) CreateBlob(ctx context.Context, body io.Reader, meta payload.BlobMeta, size uint64, opts ...menmos.UploadOption) (string, error) {
+	encrypted, meta, encryptedSize, err := c.encryptBody(ctx, body, meta, size)
+	if err != nil {
+		return "", err
+	}
+	return c.client.CreateBlobContext(ctx, encrypted, meta, encryptedSize, opts...)
+}
+
+// UpdateBlob replaces the body and metadata of a blob. The body is encrypted with a new data key.
+func (c *Client) UpdateBlob(ctx context.Context, blobID string, body io.Reader, meta payload.BlobMeta, size uint64, opts ...menmos.UploadOption) error {
+	encrypted, meta, encryptedSize, err := c.encryptBody(ctx, body, meta, size)
+	if err != nil {
+		return err
+	}
+	return c.client.UpdateBlobContext(ctx, blobID, encrypted, meta, encryptedSize, opts...)
+}
+
+// GetBody returns the decrypted body of a blob.
+// If readRange is non-nil, only the chunks covering the range are fetched and decrypted.
+func (c *Client) GetBody(ctx context.Context, blobID string, readRange *menmos.Range) (io.ReadCloser, error) {
+	meta, err := c.client.GetMetadataContext(ctx, blobID)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := c.openEnvelope(ctx, blobID, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := int64(0), env.size-1
+	if readRange != nil {
+		if readRange.Start < 0 || readRange.Start > readRange.End {
+			return nil, fmt.Errorf("invalid range for read request: %d-%d", readRange.Start, readRange.End)
+		}
+		start = readRange.Start
+		if readRange.End < end {
+			end = readRange.End
+		}
+	}
+
+	if start > end {
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	}
+
+	frameSize := env.chunkSize + overhead
+	firstChunk, lastChunk := start/env.chunkSize, end/env.chunkSize
+
+	var encryptedRange *menmos.Range
+	if readRange != nil {
+		encryptedRange = &menmos.Range{Start: firstChunk * frameSize, End: (lastChunk+1)*frameSize - 1}
+		if total := encryptedSize(env.size, env.chunkSize); encryptedRange.End >= total {
+			encryptedRange.End = total - 1
+		}
+	}
+
+	body, err := c.client.GetBodyContext(ctx, blobID, encryptedRange)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptingReader{
+		src:       body,
+		aead:      env.aead,
+		chunkSize: env.chunkSize,
+		lastIndex: chunkCount(env.size, env.chunkSize) - 1,
+		index:     firstChunk,
+		skip:      start - firstChunk*env.chunkSize,
+		limit:     end - start + 1,
+		sealed:    make([]byte, 0, frameSize),
+	}, nil
+}
+
+// GetMetadata returns the metadata of a blob, with its sensitive fields decrypted.
+func (c *Client) GetMetadata(ctx context.Context, blobID string) (payload.BlobMeta, error) {
+	meta, err := c.client.GetMetadataContext(ctx, blobID)
+	if err != nil {
+		return payload.BlobMeta{}, err
+	}
+	return c.DecryptMeta(ctx, blobID, meta)
+}
+
+// DecryptMeta decrypts the sensitive fields of the metadata of a blob, e.g. from a query hit.
+func (c *Client) DecryptMeta(ctx context.Context, blobID string, meta payload.BlobMeta) (payload.BlobMeta, error) {
+	meta = menmos.CopyMeta(meta)
+	if meta.Fields[FieldsField] == "" {
+		return meta, nil
+	}
+
+	env, err := c.openEnvelope(ctx, blobID, meta)
+	if err != nil {
+		return payload.BlobMeta{}, err
+	}
+
+	if err := openFields(blobID, env, meta); err != nil {
+		return payload.BlobMeta{}, err
+	}
+	return meta, nil
+}
+
+// UpdateMeta replaces the metadata of a blob, encrypting its sensitive fields.
+// The encryption fields of the blob are preserved.
+func (c *Client) UpdateMeta(ctx context.Context, blobID string, meta payload.BlobMeta) error {
+	current, err := c.client.GetMetadataContext(ctx, blobID)
+	if err != nil {
+		return err
+	}
+
+	env, err := c.openEnvelope(ctx, blobID, current)
+	if err != nil {
+		return err
+	}
+
+	meta = menmos.CopyMeta(meta)
+	for _, field := range []string{AlgorithmField, KeyField, KeyIDField, ChunkSizeField, SizeField} {
+		meta.Fields[field] = current.Fields[field]
+	}
+
+	if err := c.sealFields(env, meta); err != nil {
+		return err
+	}
+
+	return c.client.UpdateMetaContext(ctx, blobID, meta)
+}
@@ -0,0 +1,183 @@
+package encrypted_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/encrypted"
+	"github.com/menmos/menmos-go/internal/menmostest"
+	"github.com/menmos/menmos-go/payload"
+)
+
+func newEncryptedClient(t *testing.T, opts encrypted.Options) (*encrypted.Client, *menmostest.Server) {
+	server := menmostest.NewServer()
+	t.Cleanup(server.Close)
+
+	client, err := menmos.New(server.URL, "admin", "password", menmos.WithMaxRetryCount(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "menmos-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "kek")
+	if err := encrypted.GenerateKeyFile(keyFile); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := encrypted.NewFileKeyProvider(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encryptedClient, err := encrypted.New(client, provider, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encryptedClient, server
+}
+
+func readAll(t *testing.T, client *encrypted.Client, id string, readRange *menmos.Range) string {
+	t.Helper()
+
+	body, err := client.GetBody(context.Background(), id, readRange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func Test_EncryptedBody(t *testing.T) {
+	client, server := newEncryptedClient(t, encrypted.Options{ChunkSize: 16, SensitiveFields: []string{"customer"}})
+	ctx := context.Background()
+
+	content := "the quick brown fox jumps over the lazy dog, twice: the quick brown fox jumps over the lazy dog"
+
+	meta := payload.NewBlobMeta()
+	meta.Tags = []string{"invoice"}
+	meta.Fields["customer"] = "ACME Corp."
+	meta.Fields["year"] = "2021"
+
+	id, err := client.CreateBlob(ctx, strings.NewReader(content), meta, uint64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blob, _ := server.Blob(id)
+	if bytes.Contains(blob.Data, []byte("fox")) {
+		t.Error("body is stored in plaintext")
+	}
+	if blob.Meta.Fields["customer"] == "ACME Corp." || blob.Meta.Fields["year"] != "2021" {
+		t.Errorf("unexpected stored fields: %+v", blob.Meta.Fields)
+	}
+
+	if data := readAll(t, client, id, nil); data != content {
+		t.Errorf("unexpected body %q", data)
+	}
+
+	ranges := []menmos.Range{{Start: 0, End: 0}, {Start: 4, End: 8}, {Start: 10, End: 40}, {Start: 32, End: 47}, {Start: 90, End: 500}}
+	for _, r := range ranges {
+		end := r.End + 1
+		if end > int64(len(content)) {
+			end = int64(len(content))
+		}
+		if data := readAll(t, client, id, &r); data != content[r.Start:end] {
+			t.Errorf("range %d-%d: expected %q, got %q", r.Start, r.End, content[r.Start:end], data)
+		}
+	}
+
+	decrypted, err := client.GetMetadata(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted.Fields["customer"] != "ACME Corp." {
+		t.Errorf("field wasn't decrypted: %+v", decrypted.Fields)
+	}
+
+	decrypted.Fields["customer"] = "Globex"
+	if err := client.UpdateMeta(ctx, id, decrypted); err != nil {
+		t.Fatal(err)
+	}
+	if decrypted, err = client.GetMetadata(ctx, id); err != nil || decrypted.Fields["customer"] != "Globex" {
+		t.Errorf("field wasn't updated: %+v (%v)", decrypted.Fields, err)
+	}
+	if data := readAll(t, client, id, nil); data != content {
+		t.Errorf("body changed after metadata update: %q", data)
+	}
+
+	// Tampering with the stored body must be detected.
+	tampered := append([]byte{}, blob.Data...)
+	tampered[len(tampered)-1] ^= 1
+	server.SetData(id, tampered)
+
+	body, err := client.GetBody(ctx, id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(body); err == nil {
+		t.Error("expected tampered body to fail decryption")
+	}
+	body.Close()
+
+	// Truncating the body must be detected too.
+	server.SetData(id, blob.Data[:2*(16+28)])
+	body, err = client.GetBody(ctx, id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(body); err == nil {
+		t.Error("expected truncated body to fail decryption")
+	}
+	body.Close()
+}
+
+func Test_EncryptedEmptyBody(t *testing.T) {
+	client, _ := newEncryptedClient(t, encrypted.Options{})
+
+	id, err := client.CreateBlob(context.Background(), nil, payload.NewBlobMeta(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := readAll(t, client, id, nil); data != "" {
+		t.Errorf("expected empty body, got %q", data)
+	}
+}
+
+func Test_WrongKey(t *testing.T) {
+	client, server := newEncryptedClient(t, encrypted.Options{})
+	ctx := context.Background()
+
+	id, err := client.CreateBlob(ctx, strings.NewReader("secret"), payload.NewBlobMeta(), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain, err := menmos.New(server.URL, "admin", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := encrypted.NewStaticKeyProvider(bytes.Repeat([]byte{1}, encrypted.KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := encrypted.New(plain, otherKey, encrypted.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := other.GetBody(ctx, id, nil); err == nil {
+		t.Error("expected a different key to be rejected")
+	}
+}
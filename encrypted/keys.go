@@ -0,0 +1,157 @@
+package encrypted
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A KeyProvider wraps and unwraps the data keys protecting blobs, typically with a key encryption key
+// held by a key management service.
+type KeyProvider interface {
+	// WrapKey encrypts a data key. It returns the ID of the key encryption key used and the wrapped data key.
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+
+	// UnwrapKey decrypts a data key wrapped by the key encryption key with the provided ID.
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// KeySize is the size of data keys and local key encryption keys, in bytes (AES-256).
+const KeySize = 32
+
+// FileKeyProvider is a KeyProvider wrapping data keys with AES-GCM, using a key encryption key read from a local file.
+//
+// The key file contains 32 bytes, either raw or base64-encoded. The ID of the key is derived from its content,
+// so blobs wrapped with another key are detected instead of failing to decrypt.
+type FileKeyProvider struct {
+	id   string
+	aead cipher.AEAD
+}
+
+var _ KeyProvider = (*FileKeyProvider)(nil)
+
+// NewFileKeyProvider loads a key encryption key from a file.
+func NewFileKeyProvider(path string) (*FileKeyProvider, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read key file")
+	}
+
+	key := data
+	if len(key) != KeySize {
+		key, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("key file '%s' must contain %d bytes, raw or base64-encoded", path, KeySize)
+		}
+	}
+
+	return NewStaticKeyProvider(key)
+}
+
+// NewStaticKeyProvider returns a FileKeyProvider using a key encryption key held in memory.
+func NewStaticKeyProvider(key []byte) (*FileKeyProvider, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key encryption keys must be %d bytes long, got %d", KeySize, len(key))
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint := sha256.Sum256(key)
+	return &FileKeyProvider{id: "local:" + hex.EncodeToString(fingerprint[:8]), aead: aead}, nil
+}
+
+// GenerateKeyFile writes a new random key encryption key to a file, base64-encoded.
+// The file must not already exist.
+func GenerateKeyFile(path string) error {
+	key, err := newDataKey()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to create key file")
+	}
+
+	if _, err := fmt.Fprintln(file, base64.StdEncoding.EncodeToString(key)); err != nil {
+		file.Close()
+		return errors.Wrap(err, "failed to write key file")
+	}
+
+	return file.Close()
+}
+
+// ID returns the ID of the key encryption key.
+func (p *FileKeyProvider) ID() string {
+	return p.id
+}
+
+// WrapKey encrypts a data key with the key encryption key.
+func (p *FileKeyProvider) WrapKey(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	wrapped, err := seal(p.aead, dataKey, []byte(p.id))
+	if err != nil {
+		return "", nil, err
+	}
+	return p.id, wrapped, nil
+}
+
+// UnwrapKey decrypts a data key wrapped by WrapKey.
+func (p *FileKeyProvider) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != p.id {
+		return nil, fmt.Errorf("data key was wrapped with key '%s', provider has key '%s'", keyID, p.id)
+	}
+
+	dataKey, err := open(p.aead, wrapped, []byte(p.id))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unwrap data key")
+	}
+	return dataKey, nil
+}
+
+func newDataKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, errors.Wrap(err, "failed to generate key")
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid key")
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypts a message with a random nonce, returning the nonce followed by the ciphertext.
+func seal(aead cipher.AEAD, plaintext []byte, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrap(err, "failed to generate nonce")
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Decrypts a message encrypted by seal.
+func open(aead cipher.AEAD, sealed []byte, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
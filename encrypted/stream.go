@@ -0,0 +1,184 @@
+package encrypted
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// The body of an encrypted blob is a sequence of chunks, each holding `chunkSize` bytes of plaintext
+// (fewer for the last one) sealed independently: nonce, then ciphertext and authentication tag.
+// The additional data of a chunk is its index and whether it is the last chunk, so chunks can't be
+// reordered and bodies can't be truncated without failing authentication.
+// Empty bodies are made of a single empty chunk.
+
+const (
+	nonceSize = 12
+	tagSize   = 16
+	overhead  = nonceSize + tagSize
+)
+
+// Returns the number of chunks of a body.
+func chunkCount(plaintextSize int64, chunkSize int64) int64 {
+	if plaintextSize == 0 {
+		return 1
+	}
+	return (plaintextSize + chunkSize - 1) / chunkSize
+}
+
+// Returns the size of an encrypted body.
+func encryptedSize(plaintextSize int64, chunkSize int64) int64 {
+	return plaintextSize + chunkCount(plaintextSize, chunkSize)*overhead
+}
+
+func chunkAdditionalData(index int64, last bool) []byte {
+	ad := make([]byte, 9)
+	binary.BigEndian.PutUint64(ad, uint64(index))
+	if last {
+		ad[8] = 1
+	}
+	return ad
+}
+
+// encryptingReader encrypts a plaintext body of a known size, chunk by chunk.
+type encryptingReader struct {
+	src       io.Reader
+	aead      cipher.AEAD
+	chunkSize int64
+	remaining int64
+	lastIndex int64
+
+	index   int64
+	plain   []byte
+	pending []byte
+	done    bool
+}
+
+func newEncryptingReader(src io.Reader, aead cipher.AEAD, size int64, chunkSize int64) *encryptingReader {
+	return &encryptingReader{
+		src:       src,
+		aead:      aead,
+		chunkSize: chunkSize,
+		remaining: size,
+		lastIndex: chunkCount(size, chunkSize) - 1,
+		plain:     make([]byte, chunkSize),
+	}
+}
+
+func (r *encryptingReader) Read(buf []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.sealNext(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(buf, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *encryptingReader) sealNext() error {
+	length := r.chunkSize
+	if r.remaining < length {
+		length = r.remaining
+	}
+
+	plain := r.plain[:length]
+	if _, err := io.ReadFull(r.src, plain); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return errors.New("body is shorter than its declared size")
+		}
+		return err
+	}
+	r.remaining -= length
+
+	last := r.index == r.lastIndex
+	sealed, err := seal(r.aead, plain, chunkAdditionalData(r.index, last))
+	if err != nil {
+		return err
+	}
+
+	r.pending = sealed
+	r.index++
+	r.done = last
+	return nil
+}
+
+// decryptingReader decrypts a run of consecutive chunks, starting at chunk `index`.
+// It skips the first `skip` bytes of plaintext and returns at most `limit` bytes.
+type decryptingReader struct {
+	src       io.ReadCloser
+	aead      cipher.AEAD
+	chunkSize int64
+	lastIndex int64
+
+	index   int64
+	skip    int64
+	limit   int64
+	sealed  []byte
+	pending []byte
+}
+
+func (r *decryptingReader) Read(buf []byte) (int, error) {
+	if r.limit <= 0 {
+		return 0, io.EOF
+	}
+
+	for len(r.pending) == 0 {
+		if r.index > r.lastIndex {
+			return 0, io.EOF
+		}
+		if err := r.openNext(); err != nil {
+			return 0, err
+		}
+	}
+
+	if int64(len(buf)) > r.limit {
+		buf = buf[:r.limit]
+	}
+
+	n := copy(buf, r.pending)
+	r.pending = r.pending[n:]
+	r.limit -= int64(n)
+	return n, nil
+}
+
+func (r *decryptingReader) openNext() error {
+	sealed := r.sealed[:cap(r.sealed)]
+	n, err := io.ReadFull(r.src, sealed)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// Only the last chunk can be shorter than a full chunk.
+		if r.index != r.lastIndex || n == 0 {
+			return fmt.Errorf("encrypted body is truncated at chunk %d", r.index)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	plain, err := open(r.aead, sealed[:n], chunkAdditionalData(r.index, r.index == r.lastIndex))
+	if err != nil {
+		return errors.Wrapf(err, "failed to decrypt chunk %d", r.index)
+	}
+
+	if r.skip > 0 {
+		if r.skip > int64(len(plain)) {
+			return fmt.Errorf("chunk %d is shorter than expected", r.index)
+		}
+		plain = plain[r.skip:]
+		r.skip = 0
+	}
+
+	r.pending = plain
+	r.index++
+	return nil
+}
+
+func (r *decryptingReader) Close() error {
+	return r.src.Close()
+}
@@ -0,0 +1,52 @@
+package menmos
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/menmos/menmos-go/payload"
+)
+
+// An UploadOption customizes a single blob upload.
+type UploadOption func(*uploadOptions)
+
+type uploadOptions struct {
+	fsync bool
+}
+
+func newUploadOptions(opts []UploadOption) uploadOptions {
+	var options uploadOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// WithFsync makes an upload return only once the storage node has flushed the blob to disk.
+// If the flush fails, CreateBlob still returns the ID of the created blob with the error, and the flush can be retried with Fsync.
+func WithFsync() UploadOption {
+	return func(o *uploadOptions) {
+		o.fsync = true
+	}
+}
+
+// Fsync makes the storage node holding a blob flush it to disk.
+func (c *Client) Fsync(ctx context.Context, blobID string) (err error) {
+	ctx, finish := c.startOperation(ctx, OpFsync)
+	defer finish(&err)
+
+	req, err := c.makeJSONRequest(ctx, "POST", fmt.Sprintf("/blob/%s/fsync", blobID), nil)
+	if err != nil {
+		return err
+	}
+
+	redirectLocation, err := c.doWithRedirect(req)
+	if err != nil {
+		return err
+	}
+
+	req.URL = redirectLocation
+
+	var response payload.MessageResponse
+	return c.doJSONRequest(req, &response)
+}
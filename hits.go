@@ -0,0 +1,43 @@
+package menmos
+
+import (
+	"context"
+
+	"github.com/menmos/menmos-go/payload"
+)
+
+const hitPageSize = 100
+
+// Calls fn for every blob matching an expression, paging through the query results.
+func (c *Client) forEachHit(ctx context.Context, expr payload.Expression, fn func(hit payload.Hit) error) error {
+	var from uint32
+	for {
+		query := payload.NewStructuredQuery(expr).WithFrom(from).WithSize(hitPageSize).WithSignURLs(false)
+
+		response, err := c.QueryContext(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		for _, hit := range response.Hits {
+			if err := fn(hit); err != nil {
+				return err
+			}
+		}
+
+		from += uint32(len(response.Hits))
+		if len(response.Hits) == 0 || from >= response.Total {
+			return nil
+		}
+	}
+}
+
+// QueryAll returns every blob matching an expression, paging through the query results.
+func (c *Client) QueryAll(ctx context.Context, expr payload.Expression) ([]payload.Hit, error) {
+	var hits []payload.Hit
+	err := c.forEachHit(ctx, expr, func(hit payload.Hit) error {
+		hits = append(hits, hit)
+		return nil
+	})
+	return hits, err
+}
@@ -0,0 +1,641 @@
+// Package menmostest provides an in-memory menmos cluster for tests.
+//
+// The fake cluster is made of a directory server and a single storage node server,
+// and reproduces the redirect flow of a real menmos cluster.
+package menmostest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/menmos/menmos-go/payload"
+)
+
+// Token is the token issued by the fake cluster on login.
+const Token = "menmostest-token"
+
+// DefaultVersion is the version reported by the fake cluster, unless changed with SetVersion.
+const DefaultVersion = "0.2.6"
+
+// Password is the password accepted for any user that wasn't registered through the API.
+const Password = "password"
+
+// Blob is a blob stored in the fake cluster.
+type Blob struct {
+	Data       []byte
+	Meta       payload.BlobMeta
+	ModifiedAt time.Time
+
+	// Fsyncs is the number of times the blob was flushed through the API.
+	Fsyncs int
+}
+
+// A Request is a request received by the fake cluster.
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+}
+
+// Server is a fake menmos cluster.
+type Server struct {
+	// URL is the URL of the directory.
+	URL string
+
+	directory *httptest.Server
+	storage   *httptest.Server
+
+	mu       sync.Mutex
+	nextID   int
+	blobs    map[string]*Blob
+	users    map[string]string
+	requests []Request
+	version  string
+	routing  *payload.RoutingConfig
+	failures []*failure
+}
+
+// A failure injected with Fail.
+type failure struct {
+	method     string
+	pathSuffix string
+	status     int
+	remaining  int
+}
+
+// NewServer starts a new fake cluster. It must be closed by the caller.
+func NewServer() *Server {
+	s := &Server{blobs: make(map[string]*Blob), users: make(map[string]string), version: DefaultVersion}
+	s.storage = httptest.NewServer(http.HandlerFunc(s.serveStorage))
+	s.directory = httptest.NewServer(http.HandlerFunc(s.serveDirectory))
+	s.URL = s.directory.URL
+	return s
+}
+
+// Close shuts down the fake cluster.
+func (s *Server) Close() {
+	s.directory.Close()
+	s.storage.Close()
+}
+
+// Blob returns a copy of a stored blob.
+func (s *Server) Blob(id string) (Blob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blob, ok := s.blobs[id]
+	if !ok {
+		return Blob{}, false
+	}
+	return *blob, true
+}
+
+// BlobCount returns the number of stored blobs.
+func (s *Server) BlobCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.blobs)
+}
+
+// Put stores a blob directly, bypassing the HTTP API, and returns its ID.
+func (s *Server) Put(data []byte, meta payload.BlobMeta) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.newID()
+	s.blobs[id] = &Blob{Data: data, Meta: normalizeMeta(meta), ModifiedAt: time.Now()}
+	return id
+}
+
+// SetData replaces the content of a stored blob, bypassing the HTTP API.
+func (s *Server) SetData(id string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if blob, ok := s.blobs[id]; ok {
+		blob.Data = data
+		blob.ModifiedAt = time.Now()
+	}
+}
+
+// SetVersion changes the version reported by the fake cluster.
+func (s *Server) SetVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version = version
+}
+
+// Requests returns the requests received by the directory and the storage node, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request{}, s.requests...)
+}
+
+// Fail makes the next `count` requests with a method and a path ending with `pathSuffix` fail with a status.
+// Requests are matched on the directory and on the storage node alike, so a redirected request fails on the directory.
+func (s *Server) Fail(method string, pathSuffix string, status int, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &failure{method: method, pathSuffix: pathSuffix, status: status, remaining: count})
+}
+
+// Records a request, and answers it with an injected failure if one matches.
+// Returns whether the request was answered.
+func (s *Server) record(w http.ResponseWriter, r *http.Request) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone()})
+
+	for _, f := range s.failures {
+		if f.remaining > 0 && f.method == r.Method && strings.HasSuffix(r.URL.Path, f.pathSuffix) {
+			f.remaining--
+			writeError(w, f.status, "injected failure")
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) newID() string {
+	s.nextID++
+	return fmt.Sprintf("blob-%06d", s.nextID)
+}
+
+func normalizeMeta(meta payload.BlobMeta) payload.BlobMeta {
+	if meta.Fields == nil {
+		meta.Fields = make(map[string]string)
+	}
+	if meta.Tags == nil {
+		meta.Tags = []string{}
+	}
+	return meta
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	return r.Header.Get("Authorization") == "Bearer "+Token
+}
+
+// Splits "/blob/{id}/rest" into its ID and remaining path.
+func blobPath(path string) (string, string) {
+	trimmed := strings.TrimPrefix(path, "/blob/")
+	if idx := strings.Index(trimmed, "/"); idx >= 0 {
+		return trimmed[:idx], trimmed[idx:]
+	}
+	return trimmed, ""
+}
+
+func (s *Server) serveDirectory(w http.ResponseWriter, r *http.Request) {
+	if s.record(w, r) {
+		return
+	}
+
+	if r.URL.Path == "/auth/login" {
+		var request payload.LoginRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Username == "" {
+			writeError(w, http.StatusBadRequest, "invalid login request")
+			return
+		}
+		s.mu.Lock()
+		expected, registered := s.users[request.Username]
+		s.mu.Unlock()
+		if !registered {
+			expected = Password
+		}
+		if request.Password != expected {
+			writeError(w, http.StatusForbidden, "bad credentials")
+			return
+		}
+		writeJSON(w, http.StatusOK, payload.LoginResponse{Token: Token})
+		return
+	}
+
+	if r.URL.Path == "/version" && r.Method == http.MethodGet {
+		s.mu.Lock()
+		version := s.version
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, payload.VersionResponse{Version: version})
+		return
+	}
+
+	if r.URL.Path == "/auth/register" && r.Method == http.MethodPost {
+		s.serveRegister(w, r)
+		return
+	}
+
+	if !s.authorized(r) {
+		writeError(w, http.StatusForbidden, "unauthorized")
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/health":
+		writeJSON(w, http.StatusOK, payload.MessageResponse{Message: "healthy"})
+	case r.URL.Path == "/query" && r.Method == http.MethodPost:
+		s.serveQuery(w, r)
+	case r.URL.Path == "/node/storage":
+		writeJSON(w, http.StatusOK, payload.ListStorageNodesResponse{
+			StorageNodes: []payload.StorageNodeInfo{{ID: "storage-1", Port: 443}},
+		})
+	case r.URL.Path == "/metadata" && r.Method == http.MethodPost:
+		s.serveListMetadata(w, r)
+	case r.URL.Path == "/routing":
+		s.serveRouting(w, r)
+	case r.URL.Path == "/blob" || strings.HasPrefix(r.URL.Path, "/blob/"):
+		s.serveDirectoryBlob(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// Registers a user. Only authenticated users can register other users.
+func (s *Server) serveRegister(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeError(w, http.StatusForbidden, "unauthorized")
+		return
+	}
+
+	var request payload.RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Username == "" || request.Password == "" {
+		writeError(w, http.StatusBadRequest, "invalid register request")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[request.Username]; exists {
+		writeError(w, http.StatusBadRequest, "user already exists")
+		return
+	}
+	s.users[request.Username] = request.Password
+
+	writeJSON(w, http.StatusOK, payload.RegisterResponse{Token: Token})
+}
+
+func (s *Server) serveListMetadata(w http.ResponseWriter, r *http.Request) {
+	var request payload.ListMetadataRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid list metadata request")
+		return
+	}
+
+	keep := func(filter []string, value string) bool {
+		if len(filter) == 0 {
+			return true
+		}
+		for _, v := range filter {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	response := payload.FacetResponse{Tags: map[string]uint64{}, Meta: map[string]map[string]uint64{}}
+	for _, blob := range s.blobs {
+		for _, tag := range blob.Meta.Tags {
+			if keep(request.Tags, tag) {
+				response.Tags[tag]++
+			}
+		}
+		for k, v := range blob.Meta.Fields {
+			if !keep(request.MetaKeys, k) {
+				continue
+			}
+			if response.Meta[k] == nil {
+				response.Meta[k] = map[string]uint64{}
+			}
+			response.Meta[k][v]++
+		}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) serveRouting(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		writeJSON(w, http.StatusOK, payload.GetRoutingConfigResponse{RoutingConfig: s.routing})
+	case http.MethodPut:
+		var request payload.SetRoutingConfigRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.RoutingConfig.RoutingKey == "" {
+			writeError(w, http.StatusBadRequest, "invalid routing config")
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.routing = &request.RoutingConfig
+		writeJSON(w, http.StatusOK, payload.MessageResponse{Message: "ok"})
+	case http.MethodDelete:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.routing = nil
+		writeJSON(w, http.StatusOK, payload.MessageResponse{Message: "ok"})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) serveDirectoryBlob(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/blob" {
+		id, rest := blobPath(r.URL.Path)
+		if rest == "/metadata" && r.Method == http.MethodGet {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			var response payload.GetMetadataResponse
+			if blob, ok := s.blobs[id]; ok {
+				meta := blob.Meta
+				response.Metadata = &meta
+			}
+			writeJSON(w, http.StatusOK, response)
+			return
+		}
+	}
+
+	http.Redirect(w, r, s.storage.URL+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+}
+
+func (s *Server) serveStorage(w http.ResponseWriter, r *http.Request) {
+	if s.record(w, r) {
+		return
+	}
+
+	if !s.authorized(r) {
+		writeError(w, http.StatusForbidden, "unauthorized")
+		return
+	}
+
+	if r.URL.Path == "/blob" && r.Method == http.MethodPost {
+		s.mu.Lock()
+		id := s.newID()
+		s.mu.Unlock()
+		s.servePush(w, r, id, true)
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, "/blob/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	id, rest := blobPath(r.URL.Path)
+	switch {
+	case rest == "" && r.Method == http.MethodPost:
+		s.servePush(w, r, id, false)
+	case rest == "" && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		s.mu.Lock()
+		blob, ok := s.blobs[id]
+		var data []byte
+		var modifiedAt time.Time
+		if ok {
+			data, modifiedAt = blob.Data, blob.ModifiedAt
+		}
+		s.mu.Unlock()
+
+		if !ok {
+			writeError(w, http.StatusNotFound, "blob not found")
+			return
+		}
+		http.ServeContent(w, r, "", modifiedAt, bytes.NewReader(data))
+	case rest == "" && r.Method == http.MethodPut:
+		s.serveWrite(w, r, id)
+	case rest == "" && r.Method == http.MethodDelete:
+		s.mu.Lock()
+		delete(s.blobs, id)
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, payload.MessageResponse{Message: "ok"})
+	case rest == "/fsync" && r.Method == http.MethodPost:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		blob, ok := s.blobs[id]
+		if !ok {
+			writeError(w, http.StatusNotFound, "blob not found")
+			return
+		}
+		blob.Fsyncs++
+		writeJSON(w, http.StatusOK, payload.MessageResponse{Message: "ok"})
+	case rest == "/metadata" && r.Method == http.MethodPut:
+		var meta payload.BlobMeta
+		if err := json.NewDecoder(r.Body).Decode(&meta); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid metadata")
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		blob, ok := s.blobs[id]
+		if !ok {
+			writeError(w, http.StatusNotFound, "blob not found")
+			return
+		}
+		blob.Meta = normalizeMeta(meta)
+		writeJSON(w, http.StatusOK, payload.MessageResponse{Message: "ok"})
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// Writes the body of a request at the range of its Range header, growing the blob if needed.
+func (s *Server) serveWrite(w http.ResponseWriter, r *http.Request, id string) {
+	var start, end int
+	if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err != nil || start < 0 || end < start {
+		writeError(w, http.StatusBadRequest, "invalid range header")
+		return
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil || len(data) != end-start+1 {
+		writeError(w, http.StatusBadRequest, "body doesn't match range")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blob, ok := s.blobs[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "blob not found")
+		return
+	}
+
+	if len(blob.Data) < end+1 {
+		grown := make([]byte, end+1)
+		copy(grown, blob.Data)
+		blob.Data = grown
+	}
+	copy(blob.Data[start:], data)
+	blob.ModifiedAt = time.Now()
+
+	writeJSON(w, http.StatusOK, payload.MessageResponse{Message: "ok"})
+}
+
+func (s *Server) servePush(w http.ResponseWriter, r *http.Request, id string, create bool) {
+	metaBytes, err := base64.StdEncoding.DecodeString(r.Header.Get("X-Blob-Meta"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid blob meta header")
+		return
+	}
+
+	var meta payload.BlobMeta
+	if err := json.Unmarshal(metaBytes, &meta); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid blob meta")
+		return
+	}
+
+	size, err := strconv.ParseUint(r.Header.Get("X-Blob-Size"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid blob size header")
+		return
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read body")
+		return
+	}
+
+	if uint64(len(data)) != size {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("expected %d bytes, got %d", size, len(data)))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !create {
+		if _, ok := s.blobs[id]; !ok {
+			writeError(w, http.StatusNotFound, "blob not found")
+			return
+		}
+	}
+
+	s.blobs[id] = &Blob{Data: data, Meta: normalizeMeta(meta), ModifiedAt: time.Now()}
+	writeJSON(w, http.StatusCreated, payload.PushResponse{ID: id})
+}
+
+func (s *Server) serveQuery(w http.ResponseWriter, r *http.Request) {
+	var query payload.Query
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid query")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.blobs))
+	for id, blob := range s.blobs {
+		if matches(query.Expression, blob.Meta) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	response := payload.QueryResponse{Total: uint32(len(ids)), Hits: []payload.Hit{}}
+
+	if query.Facets {
+		response.Facets = &payload.FacetResponse{Tags: map[string]uint64{}, Meta: map[string]map[string]uint64{}}
+		for _, id := range ids {
+			meta := s.blobs[id].Meta
+			for _, tag := range meta.Tags {
+				response.Facets.Tags[tag]++
+			}
+			for k, v := range meta.Fields {
+				if response.Facets.Meta[k] == nil {
+					response.Facets.Meta[k] = map[string]uint64{}
+				}
+				response.Facets.Meta[k][v]++
+			}
+		}
+	}
+
+	size := query.Size
+	if size == 0 {
+		size = 20
+	}
+
+	for i := query.From; i < uint32(len(ids)) && i < query.From+size; i++ {
+		response.Hits = append(response.Hits, payload.Hit{
+			ID:       ids[i],
+			Metadata: s.blobs[ids[i]].Meta,
+			URL:      fmt.Sprintf("%s/blob/%s", s.storage.URL, ids[i]),
+		})
+	}
+	response.Count = uint32(len(response.Hits))
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Evaluates a decoded query expression against blob metadata.
+func matches(expression interface{}, meta payload.BlobMeta) bool {
+	switch expr := expression.(type) {
+	case nil:
+		return true
+	case string:
+		if expr == "" {
+			return true
+		}
+		return hasTag(meta, expr)
+	case map[string]interface{}:
+		if tag, ok := expr["tag"].(string); ok {
+			return hasTag(meta, tag)
+		}
+		if key, ok := expr["key"].(string); ok {
+			value, hasValue := expr["value"].(string)
+			actual, hasKey := meta.Fields[key]
+			if hasValue {
+				return hasKey && actual == value
+			}
+			return hasKey
+		}
+		if sub, ok := expr["not"]; ok {
+			return !matches(sub, meta)
+		}
+		if pair, ok := expr["and"].([]interface{}); ok && len(pair) == 2 {
+			return matches(pair[0], meta) && matches(pair[1], meta)
+		}
+		if pair, ok := expr["or"].([]interface{}); ok && len(pair) == 2 {
+			return matches(pair[0], meta) || matches(pair[1], meta)
+		}
+	}
+	return false
+}
+
+func hasTag(meta payload.BlobMeta, tag string) bool {
+	for _, t := range meta.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package menmos
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// A Logger receives structured logs about the HTTP exchanges of a client.
+//
+// Messages come with alternating key/value pairs, like the methods of *slog.Logger, which satisfies this interface.
+// Bearer tokens, blob metadata headers and URL query strings (which may hold signatures) are redacted.
+type Logger interface {
+	// Debug logs every exchange: method, url, status, duration, redirect location and blob ID.
+	Debug(msg string, keyvals ...interface{})
+
+	// Warn logs failed exchanges and retries.
+	Warn(msg string, keyvals ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, keyvals ...interface{}) {}
+func (nopLogger) Warn(msg string, keyvals ...interface{})  {}
+
+// WithLogger sets the logger receiving the logs of the client. By default, nothing is logged.
+func WithLogger(logger Logger) Option {
+	return func(o *clientOptions) error {
+		if logger == nil {
+			return errors.New("logger cannot be nil")
+		}
+		o.logger = logger
+		return nil
+	}
+}
+
+type standardLogger struct {
+	logger *log.Logger
+}
+
+// NewStandardLogger returns a Logger writing "LEVEL message key=value..." lines to a standard library logger.
+func NewStandardLogger(logger *log.Logger) Logger {
+	return &standardLogger{logger: logger}
+}
+
+func (l *standardLogger) Debug(msg string, keyvals ...interface{}) {
+	l.print("DEBUG", msg, keyvals)
+}
+
+func (l *standardLogger) Warn(msg string, keyvals ...interface{}) {
+	l.print("WARN", msg, keyvals)
+}
+
+func (l *standardLogger) print(level string, msg string, keyvals []interface{}) {
+	var line strings.Builder
+	fmt.Fprintf(&line, "%s %s", level, msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 < len(keyvals) {
+			fmt.Fprintf(&line, " %v=%v", keyvals[i], keyvals[i+1])
+		} else {
+			fmt.Fprintf(&line, " %v", keyvals[i])
+		}
+	}
+	l.logger.Print(line.String())
+}
+
+const redacted = "[REDACTED]"
+
+// Returns the headers of a request, with credentials and blob metadata redacted.
+func redactHeaders(header http.Header) map[string]string {
+	redactedHeader := make(map[string]string, len(header))
+	for name, values := range header {
+		switch http.CanonicalHeaderKey(name) {
+		case "Authorization":
+			redactedHeader[name] = "Bearer " + redacted
+		case "X-Blob-Meta":
+			redactedHeader[name] = redacted
+		default:
+			redactedHeader[name] = strings.Join(values, ", ")
+		}
+	}
+	return redactedHeader
+}
+
+// Returns a URL without its query string and user info.
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	redactedURL := *u
+	redactedURL.User = nil
+	if redactedURL.RawQuery != "" {
+		redactedURL.RawQuery = redacted
+	}
+	return redactedURL.String()
+}
+
+// Returns a transport error with the URL it carries redacted, since *url.Error includes the full URL in its message.
+func redactError(err error) error {
+	urlErr, ok := err.(*url.Error)
+	if !ok {
+		return err
+	}
+
+	redactedURL := redacted
+	if u, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+		redactedURL = redactURL(u)
+	}
+	return &url.Error{Op: urlErr.Op, URL: redactedURL, Err: urlErr.Err}
+}
+
+// Returns the ID of the blob targeted by a request path, if any.
+func blobIDFromPath(path string) string {
+	if !strings.HasPrefix(path, "/blob/") {
+		return ""
+	}
+	id := strings.TrimPrefix(path, "/blob/")
+	if idx := strings.Index(id, "/"); idx >= 0 {
+		id = id[:idx]
+	}
+	return id
+}
+
+func requestKeyvals(req *http.Request) []interface{} {
+	keyvals := []interface{}{"method", req.Method, "url", redactURL(req.URL)}
+	if blobID := blobIDFromPath(req.URL.Path); blobID != "" {
+		keyvals = append(keyvals, "blob_id", blobID)
+	}
+	return keyvals
+}
+
+// Logs a single HTTP exchange.
+func (c *Client) logExchange(req *http.Request, resp *http.Response, err error, attempt uint32, duration time.Duration) {
+	keyvals := append(requestKeyvals(req), "attempt", attempt, "duration", duration)
+	if err != nil {
+		c.logger.Warn("menmos request failed", append(keyvals, "error", redactError(err))...)
+		return
+	}
+
+	keyvals = append(keyvals, "status", resp.StatusCode, "headers", redactHeaders(req.Header))
+	if isTemporaryRedirect(resp.StatusCode) {
+		keyvals = append(keyvals, "location", redactURL(locationOf(resp)))
+	}
+	c.logger.Debug("menmos request", keyvals...)
+}
+
+func locationOf(resp *http.Response) *url.URL {
+	location, err := resp.Location()
+	if err != nil {
+		return nil
+	}
+	return location
+}
@@ -0,0 +1,134 @@
+package menmos_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/internal/menmostest"
+	"github.com/menmos/menmos-go/payload"
+)
+
+type logEntry struct {
+	level   string
+	msg     string
+	keyvals map[string]string
+}
+
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordingLogger) record(level string, msg string, keyvals []interface{}) {
+	entry := logEntry{level: level, msg: msg, keyvals: map[string]string{}}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		entry.keyvals[fmt.Sprint(keyvals[i])] = fmt.Sprint(keyvals[i+1])
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+func (l *recordingLogger) Debug(msg string, keyvals ...interface{}) { l.record("debug", msg, keyvals) }
+func (l *recordingLogger) Warn(msg string, keyvals ...interface{})  { l.record("warn", msg, keyvals) }
+
+func Test_Logger(t *testing.T) {
+	server := menmostest.NewServer()
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	client, err := menmos.New(server.URL, "admin", "password", menmos.WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta := payload.NewBlobMeta()
+	meta.Fields["secret"] = "do-not-log"
+
+	id, err := client.CreateBlob(ioutil.NopCloser(strings.NewReader("hello")), meta, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetMetadata("missing"); err == nil {
+		t.Fatal("expected missing blob to fail")
+	}
+
+	var redirects, exchanges int
+	for _, entry := range logger.entries {
+		for key, value := range entry.keyvals {
+			if strings.Contains(value, menmostest.Token) || strings.Contains(value, "ZG8tbm90LWxvZy") || strings.Contains(value, "do-not-log") {
+				t.Errorf("%s: %s leaks a secret: %s", entry.msg, key, value)
+			}
+		}
+
+		switch entry.msg {
+		case "menmos redirect":
+			redirects++
+			if entry.keyvals["storage_node"] == "" || entry.keyvals["location"] == "" {
+				t.Errorf("redirect without target: %v", entry.keyvals)
+			}
+		case "menmos request":
+			exchanges++
+			if entry.keyvals["status"] == "" || entry.keyvals["duration"] == "" {
+				t.Errorf("exchange without status or duration: %v", entry.keyvals)
+			}
+			if strings.Contains(entry.keyvals["url"], "/blob/"+id) && entry.keyvals["blob_id"] != id {
+				t.Errorf("exchange without blob ID: %v", entry.keyvals)
+			}
+		}
+	}
+
+	if redirects != 1 {
+		t.Errorf("expected 1 redirect, got %d", redirects)
+	}
+	if exchanges < 3 {
+		t.Errorf("expected at least 3 exchanges, got %d", exchanges)
+	}
+}
+
+func Test_LoggerRedactsTransportErrors(t *testing.T) {
+	// A storage node that is down, reached through a signed redirect.
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	directory := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, down.URL+r.URL.Path+"?signature=s3cret", http.StatusTemporaryRedirect)
+	}))
+	defer directory.Close()
+
+	logger := &recordingLogger{}
+	client, err := menmos.NewWithToken(directory.URL, menmostest.Token, menmos.WithLogger(logger), menmos.WithMaxRetryCount(0), menmos.WithoutDecompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.GetBody("blob", nil); err == nil {
+		t.Fatal("expected the storage node to be unreachable")
+	}
+
+	var failures int
+	for _, entry := range logger.entries {
+		for key, value := range entry.keyvals {
+			if strings.Contains(value, "s3cret") {
+				t.Errorf("%s: %s leaks the signature: %s", entry.msg, key, value)
+			}
+		}
+		if entry.msg == "menmos request failed" {
+			failures++
+			if !strings.Contains(entry.keyvals["error"], "/blob/blob") {
+				t.Errorf("expected the redacted URL in the error, got %s", entry.keyvals["error"])
+			}
+		}
+	}
+
+	if failures != 1 {
+		t.Errorf("expected 1 failed request, got %d", failures)
+	}
+}
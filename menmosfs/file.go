@@ -0,0 +1,168 @@
+package menmosfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+
+	menmos "github.com/menmos/menmos-go"
+)
+
+var errNotDir = errors.New("not a directory")
+
+// file is an open blob. The body is fetched lazily on the first read, and again after every seek.
+type file struct {
+	client *menmos.Client
+	node   *node
+	path   string
+
+	offset int64
+	body   io.ReadCloser
+	closed bool
+}
+
+// Stat returns the file info with the current size of the blob, which is what a reader gets.
+// The size of the file info returned by FS.Stat and FS.ReadDir comes from the metadata instead.
+func (f *file) Stat() (fs.FileInfo, error) {
+	size, err := f.node.fetchSize()
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: f.path, Err: err}
+	}
+	return &sizedFileInfo{FileInfo: f.node.info(), size: size}, nil
+}
+
+// sizedFileInfo is a file info with the current size of an open file.
+type sizedFileInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i *sizedFileInfo) Size() int64 {
+	return i.size
+}
+
+func (f *file) Read(buf []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.path, Err: fs.ErrClosed}
+	}
+
+	if len(buf) == 0 {
+		return 0, nil
+	}
+
+	if f.body == nil {
+		if err := f.openBody(); err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.path, Err: err}
+		}
+	}
+
+	n, err := f.body.Read(buf)
+	f.offset += int64(n)
+	return n, err
+}
+
+// Opens the blob body at the current offset. The body is read until the storage node reaches the end of the blob,
+// whatever the size recorded in its metadata.
+func (f *file) openBody() error {
+	var readRange *menmos.Range
+	if f.offset > 0 {
+		readRange = &menmos.Range{Start: f.offset, End: math.MaxInt64 - 1}
+	}
+
+	body, err := f.client.GetBodyContext(context.Background(), f.node.hit.ID, readRange)
+	if err != nil {
+		return err
+	}
+
+	f.body = body
+	return nil
+}
+
+// Seek sets the offset of the next read.
+// Seeking relative to the end fetches the current blob size from its storage node.
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.path, Err: fs.ErrClosed}
+	}
+
+	var target int64
+	switch whence {
+	case io.SeekStart:
+		target = offset
+	case io.SeekCurrent:
+		target = f.offset + offset
+	case io.SeekEnd:
+		size, err := f.node.fetchSize()
+		if err != nil {
+			return 0, &fs.PathError{Op: "seek", Path: f.path, Err: err}
+		}
+		target = size + offset
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.path, Err: fs.ErrInvalid}
+	}
+
+	if target < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.path, Err: fs.ErrInvalid}
+	}
+
+	if target != f.offset && f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	f.offset = target
+
+	return target, nil
+}
+
+func (f *file) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.path, Err: fs.ErrClosed}
+	}
+	f.closed = true
+
+	if f.body != nil {
+		return f.body.Close()
+	}
+	return nil
+}
+
+// dir is an open directory.
+type dir struct {
+	node   *node
+	path   string
+	offset int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return d.node.info(), nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.path, Err: errors.New("is a directory")}
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+func (d *dir) ReadDir(count int) ([]fs.DirEntry, error) {
+	children := d.node.sortedChildren()
+	remaining := children[d.offset:]
+
+	if count > 0 && len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > 0 && count < len(remaining) {
+		remaining = remaining[:count]
+	}
+	d.offset += len(remaining)
+
+	entries := make([]fs.DirEntry, 0, len(remaining))
+	for _, child := range remaining {
+		entries = append(entries, fs.FileInfoToDirEntry(child.info()))
+	}
+	return entries, nil
+}
@@ -0,0 +1,299 @@
+// Package menmosfs exposes the blobs matching a menmos query as an io/fs file system.
+//
+// Every blob matching the query becomes a read-only file, named by the slash-separated path stored in one of
+// its metadata fields. Directories are implied by the paths of the files they contain.
+//
+// Listings report the size recorded in the "size" field of each blob, when there is one. Open files report
+// and read the current content of the blob, which differs from that field once the blob is written in place.
+package menmosfs
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/payload"
+)
+
+// FS is a read-only file system over the blobs matching a query.
+//
+// The blob listing is fetched on first use and cached; call Reload to pick up changes.
+type FS struct {
+	client    *menmos.Client
+	expr      payload.Expression
+	pathField string
+
+	mu     sync.Mutex
+	loaded bool
+	root   *node
+}
+
+var (
+	_ fs.FS        = (*FS)(nil)
+	_ fs.ReadDirFS = (*FS)(nil)
+	_ fs.StatFS    = (*FS)(nil)
+)
+
+// New returns a file system whose files are the blobs matching expr, named by their pathField field.
+func New(client *menmos.Client, expr payload.Expression, pathField string) *FS {
+	if pathField == "" {
+		pathField = menmos.DefaultPathField
+	}
+	return &FS{client: client, expr: expr, pathField: pathField}
+}
+
+// A node is a file or directory of the tree.
+type node struct {
+	name     string
+	hit      *payload.Hit
+	modTime  time.Time
+	children map[string]*node
+
+	// The size reported by the file info comes from the size field of the blob. Files without a valid size field
+	// get their size from their storage node when it's first needed.
+	client    *menmos.Client
+	sizeMu    sync.Mutex
+	size      int64
+	sizeKnown bool
+}
+
+func (n *node) isDir() bool {
+	return n.hit == nil
+}
+
+func (n *node) info() fs.FileInfo {
+	return &fileInfo{node: n}
+}
+
+// Returns the size of a file reported by its file info, and whether it's known.
+// If the size isn't recorded in the metadata, it is fetched from the storage node and cached.
+func (n *node) fileSize() (int64, bool) {
+	n.sizeMu.Lock()
+	defer n.sizeMu.Unlock()
+
+	if n.sizeKnown || n.isDir() {
+		return n.size, n.sizeKnown
+	}
+
+	size, err := n.fetchSize()
+	if err != nil {
+		return 0, false
+	}
+
+	n.size, n.sizeKnown = size, true
+	return n.size, true
+}
+
+// Fetches the current size of a file from its storage node.
+// Unlike the size field, it accounts for blobs written in place since they were uploaded.
+func (n *node) fetchSize() (int64, error) {
+	info, err := n.client.Stat(context.Background(), n.hit.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Encoded bodies are decoded when read, so their size is the size of the original content.
+	if originalSize, err := strconv.ParseInt(info.Meta.Fields[menmos.OriginalSizeField], 10, 64); err == nil && originalSize >= 0 {
+		return originalSize, nil
+	}
+	return info.Size, nil
+}
+
+func (n *node) sortedChildren() []*node {
+	children := make([]*node, 0, len(n.children))
+	for _, child := range n.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].name < children[j].name })
+	return children
+}
+
+func newDirNode(name string) *node {
+	return &node{name: name, children: make(map[string]*node)}
+}
+
+// Builds the directory tree from query hits. Hits with invalid or conflicting paths are ignored.
+func buildTree(client *menmos.Client, hits []payload.Hit, pathField string) *node {
+	root := newDirNode(".")
+
+	for i := range hits {
+		hit := &hits[i]
+		filePath := strings.TrimPrefix(hit.Metadata.Fields[pathField], "/")
+		if !fs.ValidPath(filePath) || filePath == "." {
+			continue
+		}
+
+		parts := strings.Split(filePath, "/")
+		dir := root
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := dir.children[part]
+			if !ok {
+				child = newDirNode(part)
+				dir.children[part] = child
+			}
+			if !child.isDir() {
+				dir = nil
+				break
+			}
+			dir = child
+		}
+
+		name := parts[len(parts)-1]
+		if dir == nil {
+			continue
+		}
+		if _, exists := dir.children[name]; exists {
+			continue
+		}
+
+		file := &node{name: name, hit: hit, client: client}
+		if size, err := strconv.ParseInt(hit.Metadata.Fields[menmos.DefaultSizeField], 10, 64); err == nil && size >= 0 {
+			file.size, file.sizeKnown = size, true
+		}
+		if mtime, err := time.Parse(time.RFC3339Nano, hit.Metadata.Fields[menmos.DefaultMTimeField]); err == nil {
+			file.modTime = mtime
+		}
+		dir.children[name] = file
+	}
+
+	return root
+}
+
+// Reload refreshes the cached blob listing.
+func (f *FS) Reload(ctx context.Context) error {
+	hits, err := f.client.QueryAll(ctx, f.expr.AndHasKey(f.pathField))
+	if err != nil {
+		return err
+	}
+
+	root := buildTree(f.client, hits, f.pathField)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.root = root
+	f.loaded = true
+
+	return nil
+}
+
+func (f *FS) lookup(op string, name string) (*node, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	f.mu.Lock()
+	loaded := f.loaded
+	f.mu.Unlock()
+
+	if !loaded {
+		if err := f.Reload(context.Background()); err != nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: err}
+		}
+	}
+
+	f.mu.Lock()
+	current := f.root
+	f.mu.Unlock()
+
+	if name == "." {
+		return current, nil
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if !current.isDir() {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		child, ok := current.children[part]
+		if !ok {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		current = child
+	}
+
+	return current, nil
+}
+
+// Open opens the named file or directory.
+func (f *FS) Open(name string) (fs.File, error) {
+	n, err := f.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.isDir() {
+		return &dir{node: n, path: name}, nil
+	}
+
+	return &file{client: f.client, node: n, path: name}, nil
+}
+
+// Stat returns the file info of the named file or directory.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	n, err := f.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return n.info(), nil
+}
+
+// ReadDir returns the entries of the named directory, sorted by name.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := f.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !n.isDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+
+	children := n.sortedChildren()
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, child := range children {
+		entries = append(entries, fs.FileInfoToDirEntry(child.info()))
+	}
+	return entries, nil
+}
+
+type fileInfo struct {
+	node *node
+}
+
+func (i *fileInfo) Name() string {
+	return path.Base(i.node.name)
+}
+
+// Size returns the size of files, or 0 if it isn't recorded in the metadata and can't be fetched.
+func (i *fileInfo) Size() int64 {
+	size, _ := i.node.fileSize()
+	return size
+}
+
+func (i *fileInfo) Mode() fs.FileMode {
+	if i.node.isDir() {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (i *fileInfo) ModTime() time.Time {
+	return i.node.modTime
+}
+
+func (i *fileInfo) IsDir() bool {
+	return i.node.isDir()
+}
+
+// Sys returns the payload.Hit of files, and nil for directories.
+func (i *fileInfo) Sys() interface{} {
+	if i.node.hit == nil {
+		return nil
+	}
+	return *i.node.hit
+}
@@ -0,0 +1,175 @@
+package menmosfs_test
+
+import (
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"testing/fstest"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/internal/menmostest"
+	"github.com/menmos/menmos-go/menmosfs"
+	"github.com/menmos/menmos-go/payload"
+)
+
+func Test_FS(t *testing.T) {
+	server := menmostest.NewServer()
+	defer server.Close()
+
+	files := map[string]string{
+		"index.html":         "<h1>hello</h1>",
+		"static/app.js":      "console.log('hi')",
+		"static/css/app.css": "body {}",
+	}
+
+	for filePath, content := range files {
+		meta := payload.NewBlobMeta()
+		meta.Tags = append(meta.Tags, "site")
+		meta.Fields["path"] = filePath
+		meta.Fields["size"] = strconv.Itoa(len(content))
+		server.Put([]byte(content), meta)
+	}
+
+	// Blobs outside of the query must not show up.
+	other := payload.NewBlobMeta()
+	other.Fields["path"] = "secret.txt"
+	server.Put([]byte("secret"), other)
+
+	client, err := menmos.New(server.URL, "admin", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := menmosfs.New(client, payload.NewExpression().AndTag("site"), "path")
+
+	if err := fstest.TestFS(fsys, "index.html", "static/app.js", "static/css/app.css"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fs.Stat(fsys, "secret.txt"); err == nil {
+		t.Error("expected blobs outside of the query to be hidden")
+	}
+
+	web := httptest.NewServer(http.FileServer(http.FS(fsys)))
+	defer web.Close()
+
+	resp, err := http.Get(web.URL + "/static/app.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != files["static/app.js"] {
+		t.Errorf("unexpected body served: %q", string(body))
+	}
+}
+
+func Test_FSWithoutSizeField(t *testing.T) {
+	server := menmostest.NewServer()
+	defer server.Close()
+
+	content := "size comes from the storage node"
+	meta := payload.NewBlobMeta()
+	meta.Fields["path"] = "docs/readme.txt"
+	server.Put([]byte(content), meta)
+
+	client, err := menmos.New(server.URL, "admin", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := menmosfs.New(client, payload.NewExpression(), "path")
+
+	if err := fstest.TestFS(fsys, "docs/readme.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := fs.Stat(fsys, "docs/readme.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(content)) {
+		t.Errorf("expected size %d, got %d", len(content), info.Size())
+	}
+
+	web := httptest.NewServer(http.FileServer(http.FS(fsys)))
+	defer web.Close()
+
+	resp, err := http.Get(web.URL + "/docs/readme.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != content {
+		t.Errorf("unexpected body served: %q", string(body))
+	}
+}
+
+func Test_FSReadsPastSizeField(t *testing.T) {
+	server := menmostest.NewServer()
+	defer server.Close()
+
+	// The size field is stale, as it is for blobs grown with WriteAt or an Appender.
+	content := "grown since it was uploaded"
+	meta := payload.NewBlobMeta()
+	meta.Fields["path"] = "log.txt"
+	meta.Fields["size"] = "5"
+	server.Put([]byte(content), meta)
+
+	client, err := menmos.New(server.URL, "admin", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := menmosfs.New(client, payload.NewExpression(), "path")
+
+	data, err := fs.ReadFile(fsys, "log.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("expected the whole blob, got %q", data)
+	}
+
+	file, err := fsys.Open("log.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	seeker := file.(io.Seeker)
+	if end, err := seeker.Seek(0, io.SeekEnd); err != nil || end != int64(len(content)) {
+		t.Errorf("expected the end at %d, got %d (%v)", len(content), end, err)
+	}
+	if _, err := seeker.Seek(8, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	rest, err := ioutil.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rest) != content[8:] {
+		t.Errorf("expected %q after seeking, got %q", content[8:], rest)
+	}
+
+	web := httptest.NewServer(http.FileServer(http.FS(fsys)))
+	defer web.Close()
+
+	resp, err := http.Get(web.URL + "/log.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != content {
+		t.Errorf("unexpected body served: %q", string(body))
+	}
+}
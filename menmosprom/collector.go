@@ -0,0 +1,144 @@
+// Package menmosprom implements menmos.Metrics with Prometheus metrics.
+//
+// A Collector is a prometheus.Collector: register it with a registry to expose the measurements of the clients
+// it's attached to. The package is a separate module, so the client doesn't depend on the Prometheus client library.
+package menmosprom
+
+import (
+	"time"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultBuckets are the default upper bounds of the latency histograms, in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Options controls the naming and the buckets of the metrics.
+type Options struct {
+	// Namespace prefixes the name of every metric. Defaults to "menmos_client".
+	Namespace string
+
+	// Buckets are the upper bounds of the latency histograms, in seconds. Defaults to DefaultBuckets.
+	Buckets []float64
+
+	// ConstLabels are added to every metric, to tell apart the clients registered with the same registry.
+	ConstLabels prometheus.Labels
+}
+
+// Collector accumulates the measurements of menmos clients. It is safe for concurrent use,
+// and can be shared by several clients.
+type Collector struct {
+	operations   *prometheus.CounterVec
+	latencies    *prometheus.HistogramVec
+	uploaded     prometheus.Counter
+	downloaded   prometheus.Counter
+	retries      prometheus.Counter
+	nodeRequests *prometheus.CounterVec
+}
+
+var (
+	_ menmos.Metrics       = (*Collector)(nil)
+	_ prometheus.Collector = (*Collector)(nil)
+)
+
+// New returns an empty collector.
+func New(opts Options) *Collector {
+	if opts.Namespace == "" {
+		opts.Namespace = "menmos_client"
+	}
+	if len(opts.Buckets) == 0 {
+		opts.Buckets = DefaultBuckets
+	}
+
+	return &Collector{
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   opts.Namespace,
+			Name:        "operations_total",
+			Help:        "Client operations by outcome.",
+			ConstLabels: opts.ConstLabels,
+		}, []string{"operation", "result"}),
+		latencies: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   opts.Namespace,
+			Name:        "operation_duration_seconds",
+			Help:        "Latency of client operations.",
+			Buckets:     opts.Buckets,
+			ConstLabels: opts.ConstLabels,
+		}, []string{"operation"}),
+		uploaded: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   opts.Namespace,
+			Name:        "uploaded_bytes_total",
+			Help:        "Bytes of blob bodies sent to storage nodes.",
+			ConstLabels: opts.ConstLabels,
+		}),
+		downloaded: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   opts.Namespace,
+			Name:        "downloaded_bytes_total",
+			Help:        "Bytes of blob bodies received from storage nodes.",
+			ConstLabels: opts.ConstLabels,
+		}),
+		retries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   opts.Namespace,
+			Name:        "retries_total",
+			Help:        "Retried requests.",
+			ConstLabels: opts.ConstLabels,
+		}),
+		nodeRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   opts.Namespace,
+			Name:        "storage_node_requests_total",
+			Help:        "Requests sent to storage nodes by outcome.",
+			ConstLabels: opts.ConstLabels,
+		}, []string{"node", "result"}),
+	}
+}
+
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{c.operations, c.latencies, c.uploaded, c.downloaded, c.retries, c.nodeRequests}
+}
+
+// Describe sends the descriptors of the metrics of the collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+// Collect sends the current value of the metrics of the collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+// ObserveOperation records the outcome and latency of a client operation.
+func (c *Collector) ObserveOperation(operation string, duration time.Duration, err error) {
+	c.operations.WithLabelValues(operation, resultLabel(err != nil)).Inc()
+	c.latencies.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+// AddBytesUploaded records bytes sent to storage nodes.
+func (c *Collector) AddBytesUploaded(n int64) {
+	c.uploaded.Add(float64(n))
+}
+
+// AddBytesDownloaded records bytes received from storage nodes.
+func (c *Collector) AddBytesDownloaded(n int64) {
+	c.downloaded.Add(float64(n))
+}
+
+// IncRetries records a retried request.
+func (c *Collector) IncRetries() {
+	c.retries.Inc()
+}
+
+// ObserveStorageNodeRequest records a request sent to a storage node.
+func (c *Collector) ObserveStorageNodeRequest(node string, failed bool) {
+	c.nodeRequests.WithLabelValues(node, resultLabel(failed)).Inc()
+}
+
+func resultLabel(failed bool) string {
+	if failed {
+		return "error"
+	}
+	return "success"
+}
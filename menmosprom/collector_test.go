@@ -0,0 +1,74 @@
+package menmosprom_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/internal/menmostest"
+	"github.com/menmos/menmos-go/menmosprom"
+	"github.com/menmos/menmos-go/payload"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func Test_Collector(t *testing.T) {
+	server := menmostest.NewServer()
+	defer server.Close()
+
+	collector := menmosprom.New(menmosprom.Options{})
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(collector); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := menmos.New(server.URL, "admin", "password", menmos.WithMetrics(collector))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := client.CreateBlob(ioutil.NopCloser(strings.NewReader("hello")), payload.NewBlobMeta(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := client.GetBody(id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(body)
+	body.Close()
+
+	if _, err := client.GetBody("missing", nil); err == nil {
+		t.Fatal("expected missing blob to fail")
+	}
+
+	recorder := httptest.NewRecorder()
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	output := recorder.Body.String()
+
+	expected := []string{
+		`menmos_client_operations_total{operation="authenticate",result="success"} 1`,
+		`menmos_client_operations_total{operation="create_blob",result="success"} 1`,
+		`menmos_client_operations_total{operation="get_body",result="success"} 1`,
+		`menmos_client_operations_total{operation="get_body",result="error"} 1`,
+		`menmos_client_operation_duration_seconds_count{operation="get_body"} 2`,
+		`menmos_client_operation_duration_seconds_bucket{operation="create_blob",le="+Inf"} 1`,
+		"menmos_client_uploaded_bytes_total 5",
+		"menmos_client_downloaded_bytes_total 5",
+		"menmos_client_retries_total 0",
+		"# TYPE menmos_client_operation_duration_seconds histogram",
+	}
+	for _, line := range expected {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, output)
+		}
+	}
+
+	if !strings.Contains(output, `menmos_client_storage_node_requests_total{node="127.0.0.1:`) {
+		t.Errorf("missing storage node requests in:\n%s", output)
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/menmos/menmos-go/menmosprom
+
+go 1.16
+
+require (
+	github.com/menmos/menmos-go v0.0.0-20261018191512-d6e8a59abd04
+	github.com/prometheus/client_golang v1.10.0
+)
+
+// Builds inside this repository use the client next to the collector. Consumers ignore replace directives and get
+// the version required above, which must be bumped when the collector depends on newer client changes.
+replace github.com/menmos/menmos-go => ../
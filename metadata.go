@@ -0,0 +1,96 @@
+package menmos
+
+import (
+	"context"
+
+	"github.com/menmos/menmos-go/payload"
+)
+
+// ListMetadata returns how many blobs carry each tag and each value of each field, across the whole cluster.
+//
+// The counts are restricted to the provided tags and field keys; a nil or empty list means no restriction.
+// The result has the same structure as query facets.
+func (c *Client) ListMetadata(ctx context.Context, tags []string, keys []string) (_ *payload.FacetResponse, err error) {
+	ctx, finish := c.startOperation(ctx, OpListMetadata)
+	defer finish(&err)
+
+	req, err := c.makeJSONRequest(ctx, "POST", "/metadata", &payload.ListMetadataRequest{Tags: tags, MetaKeys: keys})
+	if err != nil {
+		return nil, err
+	}
+
+	var response payload.FacetResponse
+	if err := c.doJSONRequest(req, &response); err != nil {
+		return nil, err
+	}
+
+	return normalizeFacets(&response), nil
+}
+
+// ListMetadataForQuery is like ListMetadata, but only counts the blobs matching an expression.
+// It is computed from the facets of the query.
+func (c *Client) ListMetadataForQuery(ctx context.Context, expr payload.Expression, tags []string, keys []string) (_ *payload.FacetResponse, err error) {
+	ctx, finish := c.startOperation(ctx, OpListMetadataForQuery)
+	defer finish(&err)
+
+	response, err := c.QueryContext(ctx, payload.NewStructuredQuery(expr).WithSize(1).WithSignURLs(false).WithFacets(true))
+	if err != nil {
+		return nil, err
+	}
+
+	facets := normalizeFacets(response.Facets)
+	return &payload.FacetResponse{Tags: filterCounts(facets.Tags, tags), Meta: filterFieldCounts(facets.Meta, keys)}, nil
+}
+
+// CopyMeta returns a copy of blob metadata that can be modified without changing the original.
+// The fields and tags of the copy are never nil.
+func CopyMeta(meta payload.BlobMeta) payload.BlobMeta {
+	copied := payload.NewBlobMeta()
+	for k, v := range meta.Fields {
+		copied.Fields[k] = v
+	}
+	copied.Tags = append(copied.Tags, meta.Tags...)
+	return copied
+}
+
+// Makes sure the maps of a facet response are never nil.
+func normalizeFacets(facets *payload.FacetResponse) *payload.FacetResponse {
+	if facets == nil {
+		facets = &payload.FacetResponse{}
+	}
+	if facets.Tags == nil {
+		facets.Tags = map[string]uint64{}
+	}
+	if facets.Meta == nil {
+		facets.Meta = map[string]map[string]uint64{}
+	}
+	return facets
+}
+
+func filterCounts(counts map[string]uint64, keep []string) map[string]uint64 {
+	if len(keep) == 0 {
+		return counts
+	}
+
+	filtered := make(map[string]uint64, len(keep))
+	for _, key := range keep {
+		if count, ok := counts[key]; ok {
+			filtered[key] = count
+		}
+	}
+	return filtered
+}
+
+func filterFieldCounts(counts map[string]map[string]uint64, keep []string) map[string]map[string]uint64 {
+	if len(keep) == 0 {
+		return counts
+	}
+
+	filtered := make(map[string]map[string]uint64, len(keep))
+	for _, key := range keep {
+		if values, ok := counts[key]; ok {
+			filtered[key] = values
+		}
+	}
+	return filtered
+}
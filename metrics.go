@@ -0,0 +1,110 @@
+package menmos
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Operation names reported to Metrics.
+const (
+	OpAuthenticate         = "authenticate"
+	OpRegisterUser         = "register_user"
+	OpHealth               = "health"
+	OpQuery                = "query"
+	OpGetBody              = "get_body"
+	OpReadRange            = "read_range"
+	OpGetMetadata          = "get_metadata"
+	OpStat                 = "stat"
+	OpDelete               = "delete"
+	OpCreateBlob           = "create_blob"
+	OpUpdateBlob           = "update_blob"
+	OpUpdateMeta           = "update_meta"
+	OpFsync                = "fsync"
+	OpWriteAt              = "write_at"
+	OpAppend               = "append"
+	OpListStorageNodes     = "list_storage_nodes"
+	OpListMetadata         = "list_metadata"
+	OpListMetadataForQuery = "list_metadata_for_query"
+	OpServerVersion        = "server_version"
+	OpRequireCapability    = "require_capability"
+	OpBatchUpdateMeta      = "batch_update_meta"
+	OpBatchDelete          = "batch_delete"
+	OpGetRouting           = "get_routing_config"
+	OpSetRouting           = "set_routing_config"
+	OpDeleteRouting        = "delete_routing_config"
+	OpVerify               = "verify"
+	OpPutDedup             = "put_dedup"
+)
+
+// Metrics receives measurements of the operations of a client.
+// Implementations must be safe for concurrent use. The menmosprom package provides a Prometheus implementation.
+type Metrics interface {
+	// ObserveOperation records the outcome and latency of a client operation, named by one of the Op constants.
+	// For GetBody, the latency is the time until the body starts streaming.
+	ObserveOperation(operation string, duration time.Duration, err error)
+
+	// AddBytesUploaded records bytes of blob bodies sent to storage nodes.
+	AddBytesUploaded(n int64)
+
+	// AddBytesDownloaded records bytes of blob bodies received from storage nodes.
+	AddBytesDownloaded(n int64)
+
+	// IncRetries records a retried request.
+	IncRetries()
+
+	// ObserveStorageNodeRequest records a request sent to a storage node, identified by the host it was redirected to.
+	// A request fails if it couldn't be sent or if the storage node returned a 5xx status.
+	ObserveStorageNodeRequest(node string, failed bool)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveOperation(operation string, duration time.Duration, err error) {}
+func (nopMetrics) AddBytesUploaded(n int64)                                             {}
+func (nopMetrics) AddBytesDownloaded(n int64)                                           {}
+func (nopMetrics) IncRetries()                                                          {}
+func (nopMetrics) ObserveStorageNodeRequest(node string, failed bool)                   {}
+
+// WithMetrics sets the metrics receiving the measurements of the client. By default, nothing is measured.
+func WithMetrics(metrics Metrics) Option {
+	return func(o *clientOptions) error {
+		if metrics == nil {
+			return errors.New("metrics cannot be nil")
+		}
+		o.metrics = metrics
+		return nil
+	}
+}
+
+// Records requests sent to storage nodes, i.e. to any host other than the directory.
+func (c *Client) observeStorageNode(req *http.Request, resp *http.Response, err error) {
+	if req.URL.Host == c.directoryHost {
+		return
+	}
+	c.metrics.ObserveStorageNodeRequest(req.URL.Host, err != nil || resp.StatusCode >= 500)
+}
+
+// countingReader reports the bytes read from a body.
+type countingReader struct {
+	io.ReadCloser
+	add func(n int64)
+}
+
+func (r *countingReader) Read(buf []byte) (int, error) {
+	n, err := r.ReadCloser.Read(buf)
+	if n > 0 {
+		r.add(int64(n))
+	}
+	return n, err
+}
+
+// Wraps a downloaded body to report the bytes read from it.
+func (c *Client) countDownload(body io.ReadCloser, err error) (io.ReadCloser, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &countingReader{ReadCloser: body, add: c.metrics.AddBytesDownloaded}, nil
+}
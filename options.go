@@ -0,0 +1,271 @@
+package menmos
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/menmos/menmos-go/config"
+	"github.com/pkg/errors"
+)
+
+const defaultMaxRetryCount = 5
+
+// An Option customizes the behavior of a Client.
+type Option func(*clientOptions) error
+
+type clientOptions struct {
+	timeout            time.Duration
+	maxRetryCount      uint32
+	caFile             string
+	certFile           string
+	keyFile            string
+	serverName         string
+	insecureSkipVerify bool
+	baseTLSConfig      *tls.Config
+	proxyURL           *url.URL
+	userAgentSuffix    string
+	checksums          bool
+	compression        Codec
+	codecs             map[string]Codec
+	logger             Logger
+	metrics            Metrics
+	tracer             Tracer
+}
+
+func defaultClientOptions() clientOptions {
+	return clientOptions{
+		maxRetryCount: defaultMaxRetryCount,
+		codecs:        map[string]Codec{Gzip.Name(): Gzip},
+		logger:        nopLogger{},
+		metrics:       nopMetrics{},
+		tracer:        nopTracer{},
+	}
+}
+
+// WithTimeout sets how long the client waits for a server: to connect, to complete the TLS handshake, and to
+// receive the response headers once the request is sent. Request and response bodies aren't covered, so large
+// blobs can be streamed for longer; use the context of an operation to bound its total duration.
+// A timeout of zero means no timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) error {
+		if timeout < 0 {
+			return fmt.Errorf("invalid timeout: %s", timeout)
+		}
+		o.timeout = timeout
+		return nil
+	}
+}
+
+// WithMaxRetryCount sets how many times a request is retried on transient failures, 5 by default.
+// Transient failures are transport errors and 429, 502, 503 and 504 statuses.
+// Only idempotent requests (GET, HEAD, PUT, DELETE) are retried, with an exponential backoff.
+func WithMaxRetryCount(count uint32) Option {
+	return func(o *clientOptions) error {
+		o.maxRetryCount = count
+		return nil
+	}
+}
+
+// WithCAFile adds the PEM certificates in the specified file to the pool of trusted certificate authorities.
+func WithCAFile(path string) Option {
+	return func(o *clientOptions) error {
+		o.caFile = path
+		return nil
+	}
+}
+
+// WithClientCertificate sets the PEM certificate and private key presented by the client for mutual TLS.
+func WithClientCertificate(certFile string, keyFile string) Option {
+	return func(o *clientOptions) error {
+		if certFile == "" || keyFile == "" {
+			return errors.New("both a certificate and a key file are required for client authentication")
+		}
+		o.certFile = certFile
+		o.keyFile = keyFile
+		return nil
+	}
+}
+
+// WithTLSServerName overrides the server name used to verify the certificates of the cluster.
+func WithTLSServerName(serverName string) Option {
+	return func(o *clientOptions) error {
+		o.serverName = serverName
+		return nil
+	}
+}
+
+// WithTLSConfig sets the base TLS configuration used for connections to both the directory and the storage nodes.
+// Other TLS options are applied on top of a copy of this configuration.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(o *clientOptions) error {
+		o.baseTLSConfig = tlsConfig
+		return nil
+	}
+}
+
+// WithInsecureSkipVerify disables the verification of server certificates.
+// This should only ever be used for testing.
+func WithInsecureSkipVerify() Option {
+	return func(o *clientOptions) error {
+		o.insecureSkipVerify = true
+		return nil
+	}
+}
+
+// WithProxy routes every request through the specified proxy.
+func WithProxy(proxyURL string) Option {
+	return func(o *clientOptions) error {
+		parsed, err := url.Parse(proxyURL)
+		if err != nil {
+			return errors.Wrap(err, "invalid proxy url")
+		}
+		o.proxyURL = parsed
+		return nil
+	}
+}
+
+// WithUserAgentSuffix appends a suffix to the user agent sent with every request.
+func WithUserAgentSuffix(suffix string) Option {
+	return func(o *clientOptions) error {
+		o.userAgentSuffix = suffix
+		return nil
+	}
+}
+
+func (o *clientOptions) userAgent() string {
+	agent := fmt.Sprintf("%s/%s", userAgent, Version)
+	if o.userAgentSuffix != "" {
+		agent = fmt.Sprintf("%s %s", agent, o.userAgentSuffix)
+	}
+	return agent
+}
+
+func (o *clientOptions) tlsConfig() (*tls.Config, error) {
+	if o.baseTLSConfig == nil && o.caFile == "" && o.certFile == "" && o.serverName == "" && !o.insecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+	if o.baseTLSConfig != nil {
+		tlsConfig = o.baseTLSConfig.Clone()
+	}
+
+	if o.insecureSkipVerify {
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	if o.serverName != "" {
+		tlsConfig.ServerName = o.serverName
+	}
+
+	if o.caFile != "" {
+		pemData, err := ioutil.ReadFile(o.caFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read CA file")
+		}
+
+		pool := tlsConfig.RootCAs
+		if pool == nil {
+			pool, err = x509.SystemCertPool()
+			if err != nil || pool == nil {
+				pool = x509.NewCertPool()
+			}
+		}
+
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("no valid certificates found in CA file '%s'", o.caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if o.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.certFile, o.keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load client certificate")
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+
+	return tlsConfig, nil
+}
+
+func (o *clientOptions) httpClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	tlsConfig, err := o.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	if o.proxyURL != nil {
+		transport.Proxy = http.ProxyURL(o.proxyURL)
+	}
+
+	if o.timeout > 0 {
+		dialer := &net.Dialer{Timeout: o.timeout, KeepAlive: 30 * time.Second}
+		transport.DialContext = dialer.DialContext
+		transport.TLSHandshakeTimeout = o.timeout
+		transport.ResponseHeaderTimeout = o.timeout
+	}
+
+	return &http.Client{
+		Transport: transport,
+		// Block out redirections.
+		// We need to handle those ourselves.
+		CheckRedirect: func(redirRequest *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}, nil
+}
+
+// Translates the client settings of a profile to client options.
+func profileOptions(profile *config.Profile) ([]Option, error) {
+	var opts []Option
+
+	if profile.Timeout != "" {
+		timeout, err := time.ParseDuration(profile.Timeout)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid timeout")
+		}
+		opts = append(opts, WithTimeout(timeout))
+	}
+
+	if profile.RetryCount != nil {
+		opts = append(opts, WithMaxRetryCount(*profile.RetryCount))
+	}
+
+	if profile.CAFile != "" {
+		opts = append(opts, WithCAFile(profile.CAFile))
+	}
+
+	if profile.TLSCertFile != "" || profile.TLSKeyFile != "" {
+		opts = append(opts, WithClientCertificate(profile.TLSCertFile, profile.TLSKeyFile))
+	}
+
+	if profile.TLSServerName != "" {
+		opts = append(opts, WithTLSServerName(profile.TLSServerName))
+	}
+
+	if profile.InsecureSkipVerify {
+		opts = append(opts, WithInsecureSkipVerify())
+	}
+
+	if profile.ProxyURL != "" {
+		opts = append(opts, WithProxy(profile.ProxyURL))
+	}
+
+	if profile.UserAgentSuffix != "" {
+		opts = append(opts, WithUserAgentSuffix(profile.UserAgentSuffix))
+	}
+
+	return opts, nil
+}
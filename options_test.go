@@ -0,0 +1,249 @@
+package menmos_test
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/config"
+)
+
+// A health endpoint recording the requests it receives.
+type healthServer struct {
+	delay    time.Duration
+	failures int
+
+	mu        sync.Mutex
+	userAgent string
+	host      string
+	attempts  int
+}
+
+func (s *healthServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.userAgent, s.host = r.UserAgent(), r.Host
+	s.attempts++
+	fail := s.attempts <= s.failures
+	s.mu.Unlock()
+
+	time.Sleep(s.delay)
+	if fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte(`{"message": "healthy"}`))
+}
+
+func Test_ProfileOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "menmos-profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	retries := func(n uint32) *uint32 { return &n }
+
+	tests := []struct {
+		name    string
+		handler *healthServer
+		tls     bool
+		profile config.Profile
+		wantErr bool
+		check   func(t *testing.T, handler *healthServer)
+	}{
+		{
+			name:    "user agent suffix",
+			handler: &healthServer{},
+			profile: config.Profile{UserAgentSuffix: "nightly-backup"},
+			check: func(t *testing.T, handler *healthServer) {
+				if !strings.HasPrefix(handler.userAgent, "menmos-go/") || !strings.HasSuffix(handler.userAgent, " nightly-backup") {
+					t.Errorf("unexpected user agent '%s'", handler.userAgent)
+				}
+			},
+		},
+		{
+			name:    "timeout exceeded",
+			handler: &healthServer{delay: 200 * time.Millisecond},
+			profile: config.Profile{Timeout: "20ms", RetryCount: retries(0)},
+			wantErr: true,
+		},
+		{
+			name:    "timeout not exceeded",
+			handler: &healthServer{delay: 20 * time.Millisecond},
+			profile: config.Profile{Timeout: "5s"},
+		},
+		{
+			name:    "retry count",
+			handler: &healthServer{failures: 2},
+			profile: config.Profile{RetryCount: retries(2)},
+			check: func(t *testing.T, handler *healthServer) {
+				if handler.attempts != 3 {
+					t.Errorf("expected 3 attempts, got %d", handler.attempts)
+				}
+			},
+		},
+		{
+			name:    "retries disabled",
+			handler: &healthServer{failures: 1},
+			profile: config.Profile{RetryCount: retries(0)},
+			wantErr: true,
+		},
+		{
+			name:    "untrusted certificate",
+			handler: &healthServer{},
+			tls:     true,
+			profile: config.Profile{RetryCount: retries(0)},
+			wantErr: true,
+		},
+		{
+			name:    "ca file",
+			handler: &healthServer{},
+			tls:     true,
+			profile: config.Profile{CAFile: filepath.Join(dir, "ca.pem")},
+		},
+		{
+			name:    "insecure skip verify",
+			handler: &healthServer{},
+			tls:     true,
+			profile: config.Profile{InsecureSkipVerify: true},
+		},
+		{
+			name:    "proxy",
+			handler: &healthServer{},
+			profile: config.Profile{Host: "http://menmos.invalid"},
+			check: func(t *testing.T, handler *healthServer) {
+				if handler.host != "menmos.invalid" {
+					t.Errorf("expected the request to go through the proxy, got host '%s'", handler.host)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var server *httptest.Server
+			if tt.tls {
+				server = httptest.NewTLSServer(tt.handler)
+				caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+				if err := ioutil.WriteFile(filepath.Join(dir, "ca.pem"), caPEM, 0600); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				server = httptest.NewServer(tt.handler)
+			}
+			defer server.Close()
+
+			profile := tt.profile
+			profile.Token = "token"
+			if profile.Host == "" {
+				profile.Host = server.URL
+			} else {
+				profile.ProxyURL = server.URL
+			}
+
+			client, err := menmos.NewFromProfileConfig(&profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			healthy, err := client.IsHealthy()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected the request to fail")
+				}
+				return
+			}
+			if err != nil || !healthy {
+				t.Fatalf("expected a healthy cluster, got %v (%v)", healthy, err)
+			}
+
+			if tt.check != nil {
+				tt.handler.mu.Lock()
+				defer tt.handler.mu.Unlock()
+				tt.check(t, tt.handler)
+			}
+		})
+	}
+}
+
+func Test_ProfileOptionsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		profile  config.Profile
+		expected string
+	}{
+		{"invalid timeout", config.Profile{Timeout: "soon"}, "invalid profile"},
+		{"invalid proxy", config.Profile{ProxyURL: "://proxy"}, "invalid client option"},
+		{"missing token file", config.Profile{TokenFile: "/nonexistent/menmos-token"}, "profile: "},
+		{"missing password", config.Profile{Username: "admin", PasswordEnv: "MENMOS_TEST_UNSET_PASSWORD"}, "profile: "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := tt.profile
+			profile.Host = "http://menmos.invalid"
+			if profile.TokenFile == "" && profile.Username == "" {
+				profile.Token = "token"
+			}
+
+			_, err := menmos.NewFromProfileConfig(&profile)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.HasPrefix(err.Error(), tt.expected) {
+				t.Errorf("expected the error to start with '%s', got '%v'", tt.expected, err)
+			}
+		})
+	}
+}
+
+func Test_NewFromProfileErrorContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "menmos-profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	previous, wasSet := os.LookupEnv("XDG_CONFIG_HOME")
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	defer func() {
+		if wasSet {
+			os.Setenv("XDG_CONFIG_HOME", previous)
+		} else {
+			os.Unsetenv("XDG_CONFIG_HOME")
+		}
+	}()
+
+	cfg, err := config.LoadOrCreateDefault()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.SetProfile("ci", config.Profile{Host: "http://menmos.invalid", TokenFile: filepath.Join(dir, "missing-token")})
+	cfg.DefaultProfile = "ci"
+	if err := cfg.SaveDefault(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		profileName string
+		expected    string
+	}{
+		{"ci", "profile 'ci': "},
+		{"", "default profile: "},
+	}
+
+	for _, tt := range tests {
+		_, err := menmos.NewFromProfile(tt.profileName)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.expected) {
+			t.Errorf("expected the error to start with '%s', got '%v'", tt.expected, err)
+		}
+	}
+}
@@ -5,3 +5,21 @@ type LoginRequest struct {
 	Username string `json:"username,omitempty"`
 	Password string `json:"password,omitempty"`
 }
+
+// RegisterRequest is the data sent to menmos to register a new user.
+type RegisterRequest struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// SetRoutingConfigRequest is the data sent to menmos to replace the routing configuration of a user.
+type SetRoutingConfigRequest struct {
+	RoutingConfig RoutingConfig `json:"routing_config"`
+}
+
+// ListMetadataRequest is the data sent to menmos to list the tags and field values in use.
+// Nil lists mean no restriction.
+type ListMetadataRequest struct {
+	Tags     []string `json:"tags,omitempty"`
+	MetaKeys []string `json:"meta_keys,omitempty"`
+}
@@ -5,6 +5,11 @@ type LoginResponse struct {
 	Token string `json:"token,omitempty"`
 }
 
+// RegisterResponse is the data returned by menmos when registering a user.
+type RegisterResponse struct {
+	Token string `json:"token,omitempty"`
+}
+
 // MessageResponse is the simplest response returned by menmos.
 type MessageResponse struct {
 	Message string `json:"message,omitempty"`
@@ -50,6 +55,11 @@ type GetMetadataResponse struct {
 	Metadata *BlobMeta `json:"meta"`
 }
 
+// VersionResponse is the data returned by the version endpoint of the directory.
+type VersionResponse struct {
+	Version string `json:"version,omitempty"`
+}
+
 // StorageNodeInfo is the payload returned by ListStorageNodes.
 type StorageNodeInfo struct {
 	ID             string `json:"id,omitempty"`
@@ -61,3 +71,17 @@ type StorageNodeInfo struct {
 type ListStorageNodesResponse struct {
 	StorageNodes []StorageNodeInfo `json:"storage_nodes,omitempty"`
 }
+
+// RoutingConfig pins blobs to storage nodes according to the value of one of their fields.
+type RoutingConfig struct {
+	// RoutingKey is the field whose value selects the storage node of a blob.
+	RoutingKey string `json:"routing_key"`
+
+	// Routes maps values of the routing key to storage node IDs.
+	Routes map[string]string `json:"routes"`
+}
+
+// GetRoutingConfigResponse is the payload returned by GetRoutingConfig.
+type GetRoutingConfigResponse struct {
+	RoutingConfig *RoutingConfig `json:"routing_config"`
+}
@@ -5,6 +5,6 @@ type Range struct {
 	// Start is the start of the range (starting at 0).
 	Start int64
 
-	// End is the end of the range.
+	// End is the end of the range. Ranges ending past the end of a blob are clamped to its size.
 	End int64
 }
@@ -0,0 +1,89 @@
+package menmos
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const minRetryBackoff = 50 * time.Millisecond
+const maxRetryBackoff = 2 * time.Second
+
+// Returns whether a request can be sent again after a failed attempt.
+// Only idempotent requests are retried, since a failed attempt might still have been applied by the server:
+// uploads, queries and other POST requests are sent once.
+func isRetryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+	default:
+		return false
+	}
+
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func isTransientFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+func retryBackoff(attempt uint32) time.Duration {
+	backoff := minRetryBackoff
+	for i := uint32(1); i < attempt && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	return backoff
+}
+
+// Performs a request, retrying transient failures of idempotent requests that can be replayed.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	var attempt uint32
+	for {
+		span := c.startRequestSpan(req, attempt)
+		start := time.Now()
+		resp, err := c.httpClient.Do(req)
+		endRequestSpan(span, resp, err)
+		c.logExchange(req, resp, err, attempt, time.Since(start))
+		c.observeStorageNode(req, resp, err)
+
+		if !isTransientFailure(resp, err) || attempt >= c.maxRetryCount || !isRetryable(req) || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		attempt++
+		c.metrics.IncRetries()
+		c.logger.Warn("retrying menmos request", append(requestKeyvals(req), "attempt", attempt, "backoff", retryBackoff(attempt))...)
+
+		select {
+		case <-time.After(retryBackoff(attempt)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
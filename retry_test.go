@@ -0,0 +1,125 @@
+package menmos_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/internal/menmostest"
+	"github.com/menmos/menmos-go/payload"
+)
+
+// Counts the requests with a method and a path ending with a suffix.
+func countRequests(server *menmostest.Server, method string, pathSuffix string) int {
+	count := 0
+	for _, req := range server.Requests() {
+		if req.Method == method && strings.HasSuffix(req.Path, pathSuffix) {
+			count++
+		}
+	}
+	return count
+}
+
+func newRetryingClient(t *testing.T, server *menmostest.Server, retries uint32) *menmos.Client {
+	client, err := menmos.NewWithToken(server.URL, menmostest.Token, menmos.WithMaxRetryCount(retries))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func Test_RetryCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		retries  uint32
+		failures int
+		status   int
+		succeeds bool
+		attempts int
+	}{
+		{"no failure", 2, 0, http.StatusServiceUnavailable, true, 1},
+		{"recovers", 2, 2, http.StatusServiceUnavailable, true, 3},
+		{"recovers from rate limiting", 1, 1, http.StatusTooManyRequests, true, 2},
+		{"exhausted", 1, 2, http.StatusServiceUnavailable, false, 2},
+		{"retries disabled", 0, 1, http.StatusBadGateway, false, 1},
+		{"not transient", 3, 1, http.StatusInternalServerError, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := menmostest.NewServer()
+			defer server.Close()
+
+			id := server.Put([]byte("data"), payload.NewBlobMeta())
+			client := newRetryingClient(t, server, tt.retries)
+			server.Fail(http.MethodGet, "/metadata", tt.status, tt.failures)
+
+			_, err := client.GetMetadataContext(context.Background(), id)
+			if tt.succeeds && err != nil {
+				t.Fatal(err)
+			}
+			if !tt.succeeds && err == nil {
+				t.Fatal("expected the request to fail")
+			}
+
+			if attempts := countRequests(server, http.MethodGet, "/metadata"); attempts != tt.attempts {
+				t.Errorf("expected %d attempts, got %d", tt.attempts, attempts)
+			}
+		})
+	}
+}
+
+func Test_RetryBackoff(t *testing.T) {
+	server := menmostest.NewServer()
+	defer server.Close()
+
+	id := server.Put([]byte("data"), payload.NewBlobMeta())
+	client := newRetryingClient(t, server, 3)
+	server.Fail(http.MethodGet, "/metadata", http.StatusServiceUnavailable, 3)
+
+	start := time.Now()
+	if _, err := client.GetMetadataContext(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+
+	// The backoff starts at 50ms and doubles on every attempt.
+	if elapsed := time.Since(start); elapsed < (50+100+200)*time.Millisecond {
+		t.Errorf("expected an exponential backoff between attempts, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	server.Fail(http.MethodGet, "/metadata", http.StatusServiceUnavailable, 1)
+	if _, err := client.GetMetadataContext(ctx, id); err == nil {
+		t.Error("expected the backoff to be interrupted by the context")
+	}
+}
+
+func Test_RetrySkipsNonIdempotentRequests(t *testing.T) {
+	server := menmostest.NewServer()
+	defer server.Close()
+
+	client := newRetryingClient(t, server, 3)
+	ctx := context.Background()
+
+	// An upload streams its body to the storage node: it's neither idempotent nor replayable.
+	server.Fail(http.MethodPost, "/blob", http.StatusServiceUnavailable, 1)
+	if _, err := client.CreateBlobContext(ctx, ioutil.NopCloser(strings.NewReader("data")), payload.NewBlobMeta(), 4); err == nil {
+		t.Error("expected the upload to fail")
+	}
+	if attempts := countRequests(server, http.MethodPost, "/blob"); attempts != 1 {
+		t.Errorf("expected the upload to be sent once, got %d attempts", attempts)
+	}
+
+	server.Fail(http.MethodPost, "/auth/register", http.StatusServiceUnavailable, 1)
+	if _, err := client.RegisterUser(ctx, "ci-bot", "s3cret"); err == nil {
+		t.Error("expected the registration to fail")
+	}
+	if attempts := countRequests(server, http.MethodPost, "/auth/register"); attempts != 1 {
+		t.Errorf("expected the registration to be sent once, got %d attempts", attempts)
+	}
+}
@@ -0,0 +1,62 @@
+package menmos
+
+import (
+	"context"
+
+	"github.com/menmos/menmos-go/payload"
+	"github.com/pkg/errors"
+)
+
+// GetRoutingConfig returns the routing configuration of the authenticated user, or nil if none is set.
+func (c *Client) GetRoutingConfig(ctx context.Context) (_ *payload.RoutingConfig, err error) {
+	ctx, finish := c.startOperation(ctx, OpGetRouting)
+	defer finish(&err)
+
+	req, err := c.makeJSONRequest(ctx, "GET", "/routing", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response payload.GetRoutingConfigResponse
+	if err := c.doJSONRequest(req, &response); err != nil {
+		return nil, err
+	}
+
+	return response.RoutingConfig, nil
+}
+
+// SetRoutingConfig replaces the routing configuration of the authenticated user.
+// New blobs whose RoutingKey field matches a route are stored on the storage node of that route.
+func (c *Client) SetRoutingConfig(ctx context.Context, config payload.RoutingConfig) (err error) {
+	ctx, finish := c.startOperation(ctx, OpSetRouting)
+	defer finish(&err)
+
+	if config.RoutingKey == "" {
+		return errors.New("set routing config: routing key cannot be empty")
+	}
+	if config.Routes == nil {
+		config.Routes = map[string]string{}
+	}
+
+	req, err := c.makeJSONRequest(ctx, "PUT", "/routing", &payload.SetRoutingConfigRequest{RoutingConfig: config})
+	if err != nil {
+		return err
+	}
+
+	var response payload.MessageResponse
+	return c.doJSONRequest(req, &response)
+}
+
+// DeleteRoutingConfig removes the routing configuration of the authenticated user.
+func (c *Client) DeleteRoutingConfig(ctx context.Context) (err error) {
+	ctx, finish := c.startOperation(ctx, OpDeleteRouting)
+	defer finish(&err)
+
+	req, err := c.makeJSONRequest(ctx, "DELETE", "/routing", nil)
+	if err != nil {
+		return err
+	}
+
+	var response payload.MessageResponse
+	return c.doJSONRequest(req, &response)
+}
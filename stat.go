@@ -0,0 +1,53 @@
+package menmos
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/menmos/menmos-go/payload"
+)
+
+// BlobInfo describes a blob.
+type BlobInfo struct {
+	ID   string
+	Size int64
+	Meta payload.BlobMeta
+
+	// StorageNodeID identifies the storage node holding the blob by the address the directory redirects to.
+	StorageNodeID string
+
+	// ModifiedAt is the last modification time reported by the storage node, or the zero time if it's unknown.
+	ModifiedAt time.Time
+}
+
+// Stat returns the size, metadata, storage node and modification time of a blob.
+//
+// The size is the size stored by menmos: for blobs encoded with a codec, it's the size of the encoded content.
+// The size of the original content is recorded in the OriginalSizeField of the metadata.
+func (c *Client) Stat(ctx context.Context, blobID string) (_ BlobInfo, err error) {
+	ctx, finish := c.startOperation(ctx, OpStat)
+	defer finish(&err)
+
+	header, location, err := c.headBlob(ctx, blobID)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+
+	size, err := sizeFromHeader(blobID, header)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+
+	meta, err := c.GetMetadataContext(ctx, blobID)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+
+	info := BlobInfo{ID: blobID, Size: size, Meta: meta, StorageNodeID: location.Host}
+	if modifiedAt, err := http.ParseTime(header.Get("Last-Modified")); err == nil {
+		info.ModifiedAt = modifiedAt
+	}
+
+	return info, nil
+}
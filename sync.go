@@ -0,0 +1,702 @@
+package menmos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/menmos/menmos-go/payload"
+	"github.com/pkg/errors"
+)
+
+// DefaultChecksumField is the metadata field storing the checksum of a synced file.
+// It is the same field as the one written by WithChecksums, so synced blobs can be verified with Client.Verify.
+const DefaultChecksumField = ChecksumField
+
+// DefaultSyncStateFile is the name of the file in which bidirectional syncs record the last synced state of a tree.
+const DefaultSyncStateFile = ".menmos-sync.json"
+
+// SyncDirection controls which side of a sync is authoritative.
+type SyncDirection int
+
+const (
+	// SyncPush makes the remote blobs mirror the local tree.
+	SyncPush SyncDirection = iota
+
+	// SyncPull makes the local tree mirror the remote blobs.
+	SyncPull
+
+	// SyncBidirectional propagates changes in both directions, using a state file to tell additions from deletions.
+	SyncBidirectional
+)
+
+// ConflictPolicy controls how a file changed on both sides of a bidirectional sync is handled.
+type ConflictPolicy int
+
+const (
+	// ConflictSkip leaves conflicting files untouched and reports them in the plan.
+	ConflictSkip ConflictPolicy = iota
+
+	// ConflictPreferLocal resolves conflicts with the local version.
+	ConflictPreferLocal
+
+	// ConflictPreferRemote resolves conflicts with the remote version.
+	ConflictPreferRemote
+
+	// ConflictPreferNewest resolves conflicts with the most recently modified version.
+	ConflictPreferNewest
+)
+
+// SyncOptions controls how a local tree is compared and reconciled with remote blobs.
+type SyncOptions struct {
+	Direction SyncDirection
+	Conflicts ConflictPolicy
+
+	// PreserveDeleted prevents deletions from being propagated.
+	PreserveDeleted bool
+
+	// Meta is the base metadata of the blobs created by the sync.
+	Meta payload.BlobMeta
+
+	// PathField, MTimeField, SizeField and ChecksumField are the fields used to track files.
+	// They default to DefaultPathField, DefaultMTimeField, DefaultSizeField and DefaultChecksumField.
+	PathField     string
+	MTimeField    string
+	SizeField     string
+	ChecksumField string
+
+	// StateFile is the path of the state file of bidirectional syncs.
+	// Defaults to DefaultSyncStateFile at the root of the tree, which is excluded from the sync.
+	StateFile string
+
+	// Concurrency is the maximum number of concurrent transfers.
+	Concurrency int
+}
+
+func (o *SyncOptions) withDefaults(root string) SyncOptions {
+	opts := *o
+	opts.PathField = pathFieldOrDefault(opts.PathField)
+	if opts.MTimeField == "" {
+		opts.MTimeField = DefaultMTimeField
+	}
+	if opts.SizeField == "" {
+		opts.SizeField = DefaultSizeField
+	}
+	if opts.ChecksumField == "" {
+		opts.ChecksumField = DefaultChecksumField
+	}
+	if opts.StateFile == "" {
+		opts.StateFile = filepath.Join(root, DefaultSyncStateFile)
+	}
+	return opts
+}
+
+// SyncActionKind is the kind of operation a sync performs on a single file.
+type SyncActionKind string
+
+const (
+	SyncUpload       SyncActionKind = "upload"
+	SyncUpdate       SyncActionKind = "update"
+	SyncUpdateMeta   SyncActionKind = "update-meta"
+	SyncDownload     SyncActionKind = "download"
+	SyncTouchLocal   SyncActionKind = "touch-local"
+	SyncDeleteRemote SyncActionKind = "delete-remote"
+	SyncDeleteLocal  SyncActionKind = "delete-local"
+	SyncConflict     SyncActionKind = "conflict"
+)
+
+// A SyncAction is a single planned operation.
+type SyncAction struct {
+	Kind   SyncActionKind `json:"kind"`
+	Path   string         `json:"path"`
+	BlobID string         `json:"blob_id,omitempty"`
+	Reason string         `json:"reason"`
+
+	local  *syncFileState
+	remote *remoteSyncFile
+}
+
+// A SkippedBlob is a remote blob left out of a sync because its path can't be mapped to a local file.
+type SkippedBlob struct {
+	BlobID string `json:"blob_id"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// A SyncPlan is the minimal set of operations reconciling a local tree with remote blobs.
+type SyncPlan struct {
+	Root    string        `json:"root"`
+	Actions []SyncAction  `json:"actions"`
+	Skipped []SkippedBlob `json:"skipped,omitempty"`
+
+	expr      payload.Expression
+	opts      SyncOptions
+	unchanged map[string]syncStateEntry
+}
+
+// IsEmpty returns whether the plan has nothing to apply.
+func (p *SyncPlan) IsEmpty() bool {
+	for _, action := range p.Actions {
+		if action.Kind != SyncConflict {
+			return false
+		}
+	}
+	return true
+}
+
+// Conflicts returns the actions describing unresolved conflicts.
+func (p *SyncPlan) Conflicts() []SyncAction {
+	var conflicts []SyncAction
+	for _, action := range p.Actions {
+		if action.Kind == SyncConflict {
+			conflicts = append(conflicts, action)
+		}
+	}
+	return conflicts
+}
+
+// WriteTo writes a human-readable description of the plan, one action or skipped blob per line.
+func (p *SyncPlan) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, action := range p.Actions {
+		n, err := fmt.Fprintf(w, "%-13s %s (%s)\n", action.Kind, action.Path, action.Reason)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	for _, skipped := range p.Skipped {
+		n, err := fmt.Fprintf(w, "%-13s %s (blob '%s': %s)\n", "skipped", skipped.Path, skipped.BlobID, skipped.Reason)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// The size and modification time of a file, as seen on one side of the sync.
+type syncFileState struct {
+	Size     int64  `json:"size"`
+	MTime    string `json:"mtime"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+type remoteSyncFile struct {
+	hit   payload.Hit
+	state syncFileState
+}
+
+type syncStateEntry struct {
+	BlobID string        `json:"blob_id"`
+	Local  syncFileState `json:"local"`
+	Remote syncFileState `json:"remote"`
+}
+
+type syncState struct {
+	Files map[string]syncStateEntry `json:"files"`
+}
+
+func loadSyncState(path string) (*syncState, error) {
+	state := &syncState{Files: make(map[string]syncStateEntry)}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read sync state")
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, errors.Wrap(err, "failed to decode sync state")
+	}
+
+	if state.Files == nil {
+		state.Files = make(map[string]syncStateEntry)
+	}
+
+	return state, nil
+}
+
+func (s *syncState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode sync state")
+	}
+	return errors.Wrap(ioutil.WriteFile(path, data, 0644), "failed to write sync state")
+}
+
+func (s syncFileState) sameVersion(other syncFileState) bool {
+	if s.Size != other.Size {
+		return false
+	}
+
+	if s.Checksum != "" && other.Checksum != "" {
+		return s.Checksum == other.Checksum
+	}
+
+	return s.MTime == other.MTime
+}
+
+func (s syncFileState) newerThan(other syncFileState) bool {
+	t1, err1 := time.Parse(time.RFC3339Nano, s.MTime)
+	t2, err2 := time.Parse(time.RFC3339Nano, other.MTime)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	return t1.After(t2)
+}
+
+// planner accumulates the actions of a sync plan.
+type planner struct {
+	root  string
+	opts  SyncOptions
+	state *syncState
+	plan  *SyncPlan
+}
+
+func (p *planner) localPath(relPath string) (string, error) {
+	return localPath(p.root, relPath)
+}
+
+func (p *planner) add(kind SyncActionKind, relPath string, local *syncFileState, remote *remoteSyncFile, reason string) {
+	action := SyncAction{Kind: kind, Path: relPath, Reason: reason, local: local, remote: remote}
+	if remote != nil {
+		action.BlobID = remote.hit.ID
+	}
+	p.plan.Actions = append(p.plan.Actions, action)
+}
+
+func (p *planner) keep(relPath string, local *syncFileState, remote *remoteSyncFile) {
+	p.plan.unchanged[relPath] = syncStateEntry{BlobID: remote.hit.ID, Local: *local, Remote: remote.state}
+}
+
+// Plans the propagation of the local version of a file.
+func (p *planner) pushLocal(relPath string, local *syncFileState, remote *remoteSyncFile, reason string) {
+	if local == nil {
+		if p.opts.PreserveDeleted {
+			return
+		}
+		p.add(SyncDeleteRemote, relPath, nil, remote, reason)
+	} else if remote == nil {
+		p.add(SyncUpload, relPath, local, nil, reason)
+	} else {
+		p.add(SyncUpdate, relPath, local, remote, reason)
+	}
+}
+
+// Plans the propagation of the remote version of a file.
+func (p *planner) pullRemote(relPath string, local *syncFileState, remote *remoteSyncFile, reason string) {
+	if remote == nil {
+		if p.opts.PreserveDeleted {
+			return
+		}
+		p.add(SyncDeleteLocal, relPath, local, nil, reason)
+	} else {
+		p.add(SyncDownload, relPath, local, remote, reason)
+	}
+}
+
+func (p *planner) resolveConflict(relPath string, local *syncFileState, remote *remoteSyncFile, reason string) {
+	switch p.opts.Conflicts {
+	case ConflictPreferLocal:
+		p.pushLocal(relPath, local, remote, reason+", keeping local")
+	case ConflictPreferRemote:
+		p.pullRemote(relPath, local, remote, reason+", keeping remote")
+	case ConflictPreferNewest:
+		// A deletion has no timestamp, the surviving version is always considered the newest.
+		if remote == nil || (local != nil && local.newerThan(remote.state)) {
+			p.pushLocal(relPath, local, remote, reason+", keeping newest (local)")
+		} else {
+			p.pullRemote(relPath, local, remote, reason+", keeping newest (remote)")
+		}
+	default:
+		p.add(SyncConflict, relPath, local, remote, reason)
+	}
+}
+
+// Both sides have a file at the same path, figure out whether their contents differ.
+// If only the modification times differ, the checksums are compared before declaring a modification.
+func (p *planner) sameContent(relPath string, local *syncFileState, remote *remoteSyncFile) (bool, error) {
+	if local.Size != remote.state.Size {
+		return false, nil
+	}
+
+	if local.MTime == remote.state.MTime {
+		return true, nil
+	}
+
+	if remote.state.Checksum == "" {
+		return false, nil
+	}
+
+	filePath, err := p.localPath(relPath)
+	if err != nil {
+		return false, err
+	}
+	checksum, err := fileChecksum(filePath)
+	if err != nil {
+		return false, err
+	}
+	local.Checksum = checksum
+
+	return checksum == remote.state.Checksum, nil
+}
+
+func (p *planner) planFile(relPath string, local *syncFileState, remote *remoteSyncFile) error {
+	if local != nil && remote != nil {
+		same, err := p.sameContent(relPath, local, remote)
+		if err != nil {
+			return err
+		}
+
+		if same {
+			if local.MTime == remote.state.MTime {
+				p.keep(relPath, local, remote)
+			} else if p.opts.Direction == SyncPull {
+				p.add(SyncTouchLocal, relPath, local, remote, "same content, modification time differs")
+			} else {
+				p.add(SyncUpdateMeta, relPath, local, remote, "same content, modification time differs")
+			}
+			return nil
+		}
+	}
+
+	switch p.opts.Direction {
+	case SyncPush:
+		switch {
+		case local == nil:
+			p.pushLocal(relPath, local, remote, "deleted locally")
+		case remote == nil:
+			p.pushLocal(relPath, local, remote, "new local file")
+		default:
+			p.pushLocal(relPath, local, remote, "modified locally")
+		}
+	case SyncPull:
+		switch {
+		case remote == nil:
+			p.pullRemote(relPath, local, remote, "deleted remotely")
+		case local == nil:
+			p.pullRemote(relPath, local, remote, "new remote blob")
+		default:
+			p.pullRemote(relPath, local, remote, "modified remotely")
+		}
+	case SyncBidirectional:
+		p.planBidirectional(relPath, local, remote)
+	}
+
+	return nil
+}
+
+func (p *planner) planBidirectional(relPath string, local *syncFileState, remote *remoteSyncFile) {
+	previous, known := p.state.Files[relPath]
+	if !known {
+		switch {
+		case remote == nil:
+			p.pushLocal(relPath, local, remote, "new local file")
+		case local == nil:
+			p.pullRemote(relPath, local, remote, "new remote blob")
+		default:
+			p.resolveConflict(relPath, local, remote, "created on both sides")
+		}
+		return
+	}
+
+	localChanged := local == nil || !local.sameVersion(previous.Local)
+	remoteChanged := remote == nil || !remote.state.sameVersion(previous.Remote)
+
+	switch {
+	case local == nil && remote == nil:
+		// Deleted on both sides, nothing left to do.
+	case localChanged && !remoteChanged:
+		if local == nil {
+			p.pushLocal(relPath, local, remote, "deleted locally")
+		} else {
+			p.pushLocal(relPath, local, remote, "modified locally")
+		}
+	case remoteChanged && !localChanged:
+		if remote == nil {
+			p.pullRemote(relPath, local, remote, "deleted remotely")
+		} else {
+			p.pullRemote(relPath, local, remote, "modified remotely")
+		}
+	default:
+		p.resolveConflict(relPath, local, remote, "changed on both sides")
+	}
+}
+
+// Lists the blobs matching the expression by path.
+// Blobs whose path is invalid or escapes the synced directory are skipped, and paths shared by several blobs
+// are returned as duplicates.
+func (c *Client) listRemoteSyncFiles(ctx context.Context, expr payload.Expression, opts SyncOptions) (map[string]*remoteSyncFile, []string, []SkippedBlob, error) {
+	remoteFiles := make(map[string]*remoteSyncFile)
+	var duplicates []string
+	var skipped []SkippedBlob
+
+	err := c.forEachHit(ctx, expr.AndHasKey(opts.PathField), func(hit payload.Hit) error {
+		relPath := hit.Metadata.Fields[opts.PathField]
+		if _, err := localPath("", relPath); err != nil {
+			skipped = append(skipped, SkippedBlob{BlobID: hit.ID, Path: relPath, Reason: err.Error()})
+			return nil
+		}
+
+		if _, ok := remoteFiles[relPath]; ok {
+			duplicates = append(duplicates, relPath)
+			return nil
+		}
+
+		size, _ := strconv.ParseInt(hit.Metadata.Fields[opts.SizeField], 10, 64)
+		remoteFiles[relPath] = &remoteSyncFile{
+			hit: hit,
+			state: syncFileState{
+				Size:     size,
+				MTime:    hit.Metadata.Fields[opts.MTimeField],
+				Checksum: hit.Metadata.Fields[opts.ChecksumField],
+			},
+		}
+		return nil
+	})
+
+	return remoteFiles, duplicates, skipped, err
+}
+
+// PlanSync compares a local tree with the blobs matching an expression, keyed by their path field,
+// and returns the operations needed to reconcile them. The plan can be inspected (dry run) before being applied.
+// Remote blobs whose path can't be mapped to a local file are listed in Skipped rather than failing the plan.
+func (c *Client) PlanSync(ctx context.Context, root string, expr payload.Expression, opts SyncOptions) (*SyncPlan, error) {
+	opts = opts.withDefaults(root)
+
+	state := &syncState{Files: make(map[string]syncStateEntry)}
+	if opts.Direction == SyncBidirectional {
+		var err error
+		if state, err = loadSyncState(opts.StateFile); err != nil {
+			return nil, err
+		}
+	}
+
+	stateFile, _ := filepath.Abs(opts.StateFile)
+	localFiles, err := walkLocalFiles(root, func(relPath string, info os.FileInfo) bool {
+		absPath, _ := filepath.Abs(filepath.Join(root, filepath.FromSlash(relPath)))
+		return absPath != stateFile
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	remoteFiles, duplicates, skipped, err := c.listRemoteSyncFiles(ctx, expr, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &planner{
+		root:  root,
+		opts:  opts,
+		state: state,
+		plan:  &SyncPlan{Root: root, Skipped: skipped, expr: expr, opts: opts, unchanged: make(map[string]syncStateEntry)},
+	}
+
+	locals := make(map[string]*syncFileState, len(localFiles))
+	paths := make(map[string]struct{})
+	for _, file := range localFiles {
+		locals[file.relPath] = &syncFileState{Size: file.info.Size(), MTime: formatMTime(file.info.ModTime())}
+		paths[file.relPath] = struct{}{}
+	}
+	for relPath := range remoteFiles {
+		paths[relPath] = struct{}{}
+	}
+	for relPath := range state.Files {
+		paths[relPath] = struct{}{}
+	}
+
+	duplicated := make(map[string]bool)
+	for _, relPath := range duplicates {
+		duplicated[relPath] = true
+	}
+
+	sortedPaths := make([]string, 0, len(paths))
+	for relPath := range paths {
+		sortedPaths = append(sortedPaths, relPath)
+	}
+	sort.Strings(sortedPaths)
+
+	for _, relPath := range sortedPaths {
+		if duplicated[relPath] {
+			p.add(SyncConflict, relPath, locals[relPath], remoteFiles[relPath], "multiple blobs share this path")
+			continue
+		}
+
+		if err := p.planFile(relPath, locals[relPath], remoteFiles[relPath]); err != nil {
+			return nil, errors.Wrapf(err, "failed to compare '%s'", relPath)
+		}
+	}
+
+	return p.plan, nil
+}
+
+// Returns the metadata of a blob synced from a local file, preserving the unrelated metadata of the existing blob.
+func (o *SyncOptions) blobMeta(relPath string, local syncFileState, remote *remoteSyncFile) payload.BlobMeta {
+	base := o.Meta
+	if remote != nil {
+		base = remote.hit.Metadata
+	}
+
+	// The local file is uploaded as is, the client sets the codec fields again if it compresses it.
+	meta := CopyMeta(base)
+	removeCodecFields(meta)
+	meta.Fields[o.PathField] = relPath
+	meta.Fields[o.SizeField] = strconv.FormatInt(local.Size, 10)
+	meta.Fields[o.MTimeField] = local.MTime
+	meta.Fields[o.ChecksumField] = local.Checksum
+	return meta
+}
+
+func (c *Client) applySyncAction(ctx context.Context, plan *SyncPlan, action SyncAction) (*syncStateEntry, error) {
+	opts := plan.opts
+	// Paths come from the remote blobs and the state file as well, they must not escape the root.
+	filePath, err := localPath(plan.Root, action.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch action.Kind {
+	case SyncUpload, SyncUpdate:
+		local := *action.local
+		checksum, err := fileChecksum(filePath)
+		if err != nil {
+			return nil, err
+		}
+		local.Checksum = checksum
+
+		blobID := ""
+		if action.remote != nil {
+			blobID = action.remote.hit.ID
+		}
+
+		meta := opts.blobMeta(action.Path, local, action.remote)
+		if blobID, err = c.uploadFile(ctx, filePath, blobID, meta); err != nil {
+			return nil, err
+		}
+		return &syncStateEntry{BlobID: blobID, Local: local, Remote: local}, nil
+	case SyncUpdateMeta:
+		local := *action.local
+		local.Checksum = action.remote.state.Checksum
+		if err := c.UpdateMetaContext(ctx, action.BlobID, opts.blobMeta(action.Path, local, action.remote)); err != nil {
+			return nil, err
+		}
+		return &syncStateEntry{BlobID: action.BlobID, Local: local, Remote: local}, nil
+	case SyncDownload, SyncTouchLocal:
+		remote := action.remote.state
+		mtime, err := time.Parse(time.RFC3339Nano, remote.MTime)
+		hasMTime := err == nil
+
+		if action.Kind == SyncDownload {
+			var mtimePtr *time.Time
+			if hasMTime {
+				mtimePtr = &mtime
+			}
+			if err := c.downloadFile(ctx, action.BlobID, filePath, mtimePtr); err != nil {
+				return nil, err
+			}
+		} else if hasMTime {
+			if err := os.Chtimes(filePath, mtime, mtime); err != nil {
+				return nil, err
+			}
+		}
+
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return nil, err
+		}
+		local := syncFileState{Size: info.Size(), MTime: formatMTime(info.ModTime()), Checksum: remote.Checksum}
+		return &syncStateEntry{BlobID: action.BlobID, Local: local, Remote: remote}, nil
+	case SyncDeleteRemote:
+		return nil, c.DeleteContext(ctx, action.BlobID)
+	case SyncDeleteLocal:
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unsupported sync action '%s'", action.Kind)
+}
+
+// ApplySync performs the operations of a sync plan.
+// Conflicts left unresolved by the plan are skipped. For bidirectional syncs, the state file is updated
+// with every successfully applied operation, even if others fail.
+func (c *Client) ApplySync(ctx context.Context, plan *SyncPlan) error {
+	var mu sync.Mutex
+	newState := &syncState{Files: make(map[string]syncStateEntry)}
+	for relPath, entry := range plan.unchanged {
+		newState.Files[relPath] = entry
+	}
+
+	var previous *syncState
+	if plan.opts.Direction == SyncBidirectional {
+		var err error
+		if previous, err = loadSyncState(plan.opts.StateFile); err != nil {
+			return err
+		}
+	}
+
+	var failures []string
+	err := runConcurrently(ctx, plan.opts.Concurrency, len(plan.Actions), func(ctx context.Context, i int) error {
+		action := plan.Actions[i]
+		if action.Kind == SyncConflict {
+			// Remember what we knew about conflicting files so they stay conflicting until resolved.
+			if previous != nil {
+				if entry, ok := previous.Files[action.Path]; ok {
+					mu.Lock()
+					newState.Files[action.Path] = entry
+					mu.Unlock()
+				}
+			}
+			return nil
+		}
+
+		entry, err := c.applySyncAction(ctx, plan, action)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s '%s': %v", action.Kind, action.Path, err))
+			if previous != nil {
+				if entry, ok := previous.Files[action.Path]; ok {
+					newState.Files[action.Path] = entry
+				}
+			}
+			return nil
+		}
+
+		if entry != nil {
+			newState.Files[action.Path] = *entry
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if plan.opts.Direction == SyncBidirectional {
+		if err := newState.save(plan.opts.StateFile); err != nil {
+			return err
+		}
+	}
+
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return fmt.Errorf("%d sync operations failed: %s", len(failures), strings.Join(failures, "; "))
+	}
+
+	return nil
+}
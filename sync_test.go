@@ -0,0 +1,224 @@
+package menmos_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/payload"
+)
+
+func planKinds(plan *menmos.SyncPlan) map[string]menmos.SyncActionKind {
+	kinds := make(map[string]menmos.SyncActionKind)
+	for _, action := range plan.Actions {
+		kinds[action.Path] = action.Kind
+	}
+	return kinds
+}
+
+func expectPlan(t *testing.T, plan *menmos.SyncPlan, expected map[string]menmos.SyncActionKind) {
+	t.Helper()
+
+	actual := planKinds(plan)
+	if len(actual) != len(expected) {
+		var buf bytes.Buffer
+		plan.WriteTo(&buf)
+		t.Fatalf("expected %d actions, got:\n%s", len(expected), buf.String())
+	}
+
+	for relPath, kind := range expected {
+		if actual[relPath] != kind {
+			t.Errorf("%s: expected %s, got %s", relPath, kind, actual[relPath])
+		}
+	}
+}
+
+func syncOnce(t *testing.T, client *menmos.Client, root string, opts menmos.SyncOptions, expected map[string]menmos.SyncActionKind) {
+	t.Helper()
+
+	ctx := context.Background()
+	expr := payload.NewExpression().AndTag("synced")
+
+	plan, err := client.PlanSync(ctx, root, expr, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectPlan(t, plan, expected)
+
+	if err := client.ApplySync(ctx, plan); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func touch(t *testing.T, path string, content string, mtime time.Time) {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_SyncPush(t *testing.T) {
+	client, server := newTestClient(t)
+	root := tempDir(t)
+	writeTestTree(t, root, map[string]string{"a.txt": "a", "dir/b.txt": "b", "dir/c.txt": "c"})
+
+	opts := menmos.SyncOptions{Direction: menmos.SyncPush, Meta: payload.BlobMeta{Tags: []string{"synced"}}}
+
+	syncOnce(t, client, root, opts, map[string]menmos.SyncActionKind{
+		"a.txt":     menmos.SyncUpload,
+		"dir/b.txt": menmos.SyncUpload,
+		"dir/c.txt": menmos.SyncUpload,
+	})
+	syncOnce(t, client, root, opts, map[string]menmos.SyncActionKind{})
+
+	touch(t, filepath.Join(root, "a.txt"), "a modified", time.Now().Add(time.Minute))
+	if err := os.Remove(filepath.Join(root, "dir", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	// Same content, new modification time: only the metadata should be updated.
+	later := time.Now().Add(2 * time.Minute)
+	if err := os.Chtimes(filepath.Join(root, "dir", "c.txt"), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	syncOnce(t, client, root, opts, map[string]menmos.SyncActionKind{
+		"a.txt":     menmos.SyncUpdate,
+		"dir/b.txt": menmos.SyncDeleteRemote,
+		"dir/c.txt": menmos.SyncUpdateMeta,
+	})
+	syncOnce(t, client, root, opts, map[string]menmos.SyncActionKind{})
+
+	if server.BlobCount() != 2 {
+		t.Errorf("expected 2 blobs, got %d", server.BlobCount())
+	}
+}
+
+func Test_SyncBidirectional(t *testing.T) {
+	client, server := newTestClient(t)
+	root := tempDir(t)
+	writeTestTree(t, root, map[string]string{"local.txt": "local", "shared.txt": "shared"})
+
+	remoteMeta := payload.BlobMeta{Tags: []string{"synced"}, Fields: map[string]string{"path": "remote.txt", "size": "6", "mtime": "2021-01-01T00:00:00Z"}}
+	remoteID := server.Put([]byte("remote"), remoteMeta)
+
+	opts := menmos.SyncOptions{Direction: menmos.SyncBidirectional, Meta: payload.BlobMeta{Tags: []string{"synced"}}}
+
+	syncOnce(t, client, root, opts, map[string]menmos.SyncActionKind{
+		"local.txt":  menmos.SyncUpload,
+		"shared.txt": menmos.SyncUpload,
+		"remote.txt": menmos.SyncDownload,
+	})
+	syncOnce(t, client, root, opts, map[string]menmos.SyncActionKind{})
+
+	data, err := ioutil.ReadFile(filepath.Join(root, "remote.txt"))
+	if err != nil || string(data) != "remote" {
+		t.Fatalf("expected remote.txt to be downloaded, got %q (%v)", string(data), err)
+	}
+
+	// Modify the remote blob, delete a local file and change the shared file on both sides.
+	remoteMeta.Fields["size"] = "14"
+	remoteMeta.Fields["mtime"] = "2021-01-02T00:00:00Z"
+	if err := client.UpdateBlob(remoteID, ioutil.NopCloser(bytes.NewReader([]byte("remote updated"))), remoteMeta, 14); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(filepath.Join(root, "local.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := client.PlanSync(context.Background(), root, payload.NewExpression().AndTag("synced"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range plan.Actions {
+		if action.Path == "shared.txt" {
+			t.Fatalf("shared.txt should be in sync")
+		}
+	}
+
+	hits, err := client.Query(payload.NewStructuredQuery(payload.NewExpression().AndKeyValue("path", "shared.txt")))
+	if err != nil || len(hits.Hits) != 1 {
+		t.Fatalf("expected a single shared blob: %v", err)
+	}
+	sharedID := hits.Hits[0].ID
+	sharedMeta := hits.Hits[0].Metadata
+	sharedMeta.Fields["size"] = "13"
+	sharedMeta.Fields["mtime"] = "2030-01-01T00:00:00Z"
+	delete(sharedMeta.Fields, menmos.ChecksumField)
+	if err := client.UpdateBlob(sharedID, ioutil.NopCloser(bytes.NewReader([]byte("shared remote"))), sharedMeta, 13); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, filepath.Join(root, "shared.txt"), "shared local", time.Now())
+
+	syncOnce(t, client, root, opts, map[string]menmos.SyncActionKind{
+		"local.txt":  menmos.SyncDeleteRemote,
+		"remote.txt": menmos.SyncDownload,
+		"shared.txt": menmos.SyncConflict,
+	})
+
+	// The conflict stays until resolved.
+	syncOnce(t, client, root, opts, map[string]menmos.SyncActionKind{
+		"shared.txt": menmos.SyncConflict,
+	})
+
+	opts.Conflicts = menmos.ConflictPreferNewest
+	syncOnce(t, client, root, opts, map[string]menmos.SyncActionKind{
+		"shared.txt": menmos.SyncDownload,
+	})
+
+	data, err = ioutil.ReadFile(filepath.Join(root, "shared.txt"))
+	if err != nil || string(data) != "shared remote" {
+		t.Fatalf("expected the newest (remote) version to win, got %q (%v)", string(data), err)
+	}
+}
+
+func Test_SyncSkipsInvalidRemotePaths(t *testing.T) {
+	client, server := newTestClient(t)
+	root := tempDir(t)
+
+	for relPath, content := range map[string]string{"good.txt": "good", "../escape.txt": "bad", "/abs.txt": "bad", `..\escape.txt`: "bad"} {
+		meta := payload.NewBlobMeta()
+		meta.Tags = append(meta.Tags, "synced")
+		meta.Fields[menmos.DefaultPathField] = relPath
+		server.Put([]byte(content), meta)
+	}
+
+	opts := menmos.SyncOptions{Direction: menmos.SyncPull}
+	plan, err := client.PlanSync(context.Background(), root, payload.NewExpression().AndTag("synced"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectPlan(t, plan, map[string]menmos.SyncActionKind{"good.txt": menmos.SyncDownload})
+
+	if len(plan.Skipped) != 3 {
+		t.Fatalf("expected 3 skipped blobs, got %+v", plan.Skipped)
+	}
+	for _, skipped := range plan.Skipped {
+		if skipped.BlobID == "" || skipped.Reason == "" || skipped.Path == "good.txt" {
+			t.Errorf("unexpected skipped blob %+v", skipped)
+		}
+	}
+
+	var buf bytes.Buffer
+	plan.WriteTo(&buf)
+	if !bytes.Contains(buf.Bytes(), []byte("skipped       ../escape.txt")) {
+		t.Errorf("expected the skipped blobs in the description:\n%s", buf.String())
+	}
+
+	if err := client.ApplySync(context.Background(), plan); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(root, "good.txt")); err != nil || string(data) != "good" {
+		t.Errorf("unexpected content %q (%v)", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(root), "escape.txt")); !os.IsNotExist(err) {
+		t.Error("expected the escaping blob not to be downloaded")
+	}
+}
@@ -0,0 +1,132 @@
+package menmos
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// A Tracer creates the spans tracing the operations of a client.
+//
+// Every public operation of a client gets a span, named "menmos." followed by one of the Op constants.
+// Every HTTP request made for an operation gets a child span, named "menmos.directory" or "menmos.storage_node"
+// depending on the server it is sent to, and carries the W3C traceparent header of that span so the
+// server side can be correlated.
+//
+// Operations returning a reader end their span once the reader is returned: the span of GetBody covers the
+// requests up to the response headers, not the transfer of the body. Reads of a range are made lazily, and each
+// of them gets its own "menmos.read_range" span, a child of the span of GetBody.
+//
+// Spans are parented through the context: Start receives the context of the parent span, and the context it
+// returns is passed to the children. Adapters to OpenTelemetry typically wrap trace.Tracer and trace.Span.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// A Span is a traced unit of work.
+type Span interface {
+	// SetAttribute annotates the span.
+	SetAttribute(key string, value interface{})
+
+	// TraceParent returns the W3C traceparent header value identifying the span, or an empty string to
+	// propagate nothing.
+	TraceParent() string
+
+	// End completes the span. err is the error the work failed with, if any.
+	End(err error)
+}
+
+// FormatTraceParent formats a W3C traceparent header value, for Span implementations.
+func FormatTraceParent(traceID [16]byte, spanID [8]byte, sampled bool) string {
+	flags := "00"
+	if sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(traceID[:]) + "-" + hex.EncodeToString(spanID[:]) + "-" + flags
+}
+
+type nopTracer struct{}
+
+type nopSpan struct{}
+
+func (nopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+func (nopSpan) SetAttribute(key string, value interface{}) {}
+func (nopSpan) TraceParent() string                        { return "" }
+func (nopSpan) End(err error)                              {}
+
+// WithTracer sets the tracer creating the spans of the client. By default, nothing is traced.
+func WithTracer(tracer Tracer) Option {
+	return func(o *clientOptions) error {
+		if tracer == nil {
+			return errors.New("tracer cannot be nil")
+		}
+		o.tracer = tracer
+		return nil
+	}
+}
+
+// Starts the span of a public operation. The returned function ends the span and records the operation metrics,
+// and must be deferred with a pointer to the named error of the operation.
+func (c *Client) startOperation(ctx context.Context, operation string) (context.Context, func(err *error)) {
+	start := time.Now()
+	ctx, span := c.tracer.Start(ctx, "menmos."+operation)
+
+	return ctx, func(err *error) {
+		c.metrics.ObserveOperation(operation, time.Since(start), *err)
+		span.End(*err)
+	}
+}
+
+// Starts the span of a single HTTP request and propagates it to the server.
+func (c *Client) startRequestSpan(req *http.Request, attempt uint32) Span {
+	name := "menmos.directory"
+	if req.URL.Host != c.directoryHost {
+		name = "menmos.storage_node"
+	}
+
+	_, span := c.tracer.Start(req.Context(), name)
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", redactURL(req.URL))
+	if blobID := blobIDFromPath(req.URL.Path); blobID != "" {
+		span.SetAttribute("menmos.blob_id", blobID)
+	}
+	if req.URL.Host != c.directoryHost {
+		span.SetAttribute("menmos.storage_node", req.URL.Host)
+	}
+	if attempt > 0 {
+		span.SetAttribute("menmos.retry_attempt", attempt)
+	}
+
+	if traceParent := span.TraceParent(); traceParent != "" {
+		req.Header.Set("traceparent", traceParent)
+	} else {
+		req.Header.Del("traceparent")
+	}
+
+	return span
+}
+
+// Ends the span of a single HTTP request.
+func endRequestSpan(span Span, resp *http.Response, err error) {
+	if err != nil {
+		span.End(redactError(err))
+		return
+	}
+
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if isTemporaryRedirect(resp.StatusCode) {
+		span.SetAttribute("http.redirect_location", redactURL(locationOf(resp)))
+	}
+
+	if resp.StatusCode >= 400 {
+		span.End(errors.Errorf("unexpected status '%s'", resp.Status))
+		return
+	}
+	span.End(nil)
+}
@@ -0,0 +1,207 @@
+package menmos_test
+
+import (
+	"context"
+	"crypto/rand"
+	"strings"
+	"sync"
+	"testing"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/internal/menmostest"
+	"github.com/menmos/menmos-go/payload"
+)
+
+type testSpan struct {
+	name       string
+	parent     *testSpan
+	traceID    [16]byte
+	spanID     [8]byte
+	attributes map[string]interface{}
+	ended      bool
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) { s.attributes[key] = value }
+func (s *testSpan) TraceParent() string                        { return menmos.FormatTraceParent(s.traceID, s.spanID, true) }
+func (s *testSpan) End(err error)                              { s.ended = true }
+
+type spanKey struct{}
+
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+func (tr *testTracer) Start(ctx context.Context, name string) (context.Context, menmos.Span) {
+	span := &testSpan{name: name, attributes: map[string]interface{}{}}
+	rand.Read(span.spanID[:])
+	if parent, ok := ctx.Value(spanKey{}).(*testSpan); ok {
+		span.parent = parent
+		span.traceID = parent.traceID
+	} else {
+		rand.Read(span.traceID[:])
+	}
+
+	tr.mu.Lock()
+	tr.spans = append(tr.spans, span)
+	tr.mu.Unlock()
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func Test_Tracer(t *testing.T) {
+	server := menmostest.NewServer()
+	defer server.Close()
+
+	id := server.Put([]byte("hello"), payload.NewBlobMeta())
+
+	tracer := &testTracer{}
+	client, err := menmos.NewWithToken(server.URL, menmostest.Token, menmos.WithTracer(tracer), menmos.WithoutDecompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := client.GetBody(id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Close()
+
+	if len(tracer.spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(tracer.spans))
+	}
+
+	root, directory, storage := tracer.spans[0], tracer.spans[1], tracer.spans[2]
+	if root.name != "menmos.get_body" || root.parent != nil {
+		t.Errorf("unexpected root span %+v", root)
+	}
+	if directory.name != "menmos.directory" || directory.parent != root {
+		t.Errorf("unexpected directory span %+v", directory)
+	}
+	if storage.name != "menmos.storage_node" || storage.parent != root || storage.attributes["menmos.storage_node"] == nil {
+		t.Errorf("unexpected storage node span %+v", storage)
+	}
+	if directory.attributes["menmos.blob_id"] != id || directory.attributes["http.redirect_location"] == nil {
+		t.Errorf("missing directory span attributes: %v", directory.attributes)
+	}
+	for _, span := range tracer.spans {
+		if !span.ended {
+			t.Errorf("span %s wasn't ended", span.name)
+		}
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	for i, span := range []*testSpan{directory, storage} {
+		if traceParent := requests[i].Header.Get("traceparent"); traceParent != span.TraceParent() {
+			t.Errorf("request %d: expected traceparent %s, got %s", i, span.TraceParent(), traceParent)
+		}
+		if !strings.HasPrefix(requests[i].Header.Get("traceparent"), "00-") {
+			t.Errorf("request %d: malformed traceparent", i)
+		}
+	}
+}
+
+func Test_TracerRangeRead(t *testing.T) {
+	server := menmostest.NewServer()
+	defer server.Close()
+
+	id := server.Put([]byte("hello world"), payload.NewBlobMeta())
+
+	tracer := &testTracer{}
+	client, err := menmos.NewWithToken(server.URL, menmostest.Token, menmos.WithTracer(tracer), menmos.WithoutDecompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := client.GetBody(id, &menmos.Range{Start: 0, End: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	if len(tracer.spans) != 1 || !tracer.spans[0].ended {
+		t.Fatalf("expected only the ended get_body span before reading, got %d spans", len(tracer.spans))
+	}
+
+	buf := make([]byte, 5)
+	if _, err := body.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// The read gets its own span, whose requests are its children rather than children of the ended get_body span.
+	if len(tracer.spans) != 4 {
+		t.Fatalf("expected 4 spans, got %d", len(tracer.spans))
+	}
+	read := tracer.spans[1]
+	if read.name != "menmos.read_range" || read.parent != tracer.spans[0] || !read.ended {
+		t.Errorf("unexpected read span %+v", read)
+	}
+	for _, span := range tracer.spans[2:] {
+		if span.parent != read {
+			t.Errorf("expected span %s to be a child of the read span", span.name)
+		}
+	}
+}
+
+func Test_TracerOperations(t *testing.T) {
+	server := menmostest.NewServer()
+	defer server.Close()
+
+	id := server.Put([]byte("hello"), payload.NewBlobMeta())
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		op   func(client *menmos.Client) error
+	}{
+		{"menmos.server_version", func(client *menmos.Client) error {
+			_, err := client.ServerVersion(ctx)
+			return err
+		}},
+		{"menmos.require_capability", func(client *menmos.Client) error {
+			return client.RequireCapability(ctx, menmos.CapabilityRangedWrites)
+		}},
+		{"menmos.list_metadata_for_query", func(client *menmos.Client) error {
+			_, err := client.ListMetadataForQuery(ctx, payload.NewExpression(), nil, nil)
+			return err
+		}},
+		{"menmos.batch_update_meta", func(client *menmos.Client) error {
+			_, err := client.BatchUpdateMeta(ctx, []string{id}, func(meta *payload.BlobMeta) {}, menmos.BatchOptions{})
+			return err
+		}},
+		{"menmos.batch_delete", func(client *menmos.Client) error {
+			_, err := client.BatchDelete(ctx, []string{id}, menmos.BatchOptions{})
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer := &testTracer{}
+			client, err := menmos.NewWithToken(server.URL, menmostest.Token, menmos.WithTracer(tracer))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.op(client); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(tracer.spans) == 0 {
+				t.Fatal("expected spans")
+			}
+			root := tracer.spans[0]
+			if root.name != tt.name || root.parent != nil || !root.ended {
+				t.Errorf("unexpected root span %+v", root)
+			}
+			for _, span := range tracer.spans[1:] {
+				if span.parent == nil {
+					t.Errorf("expected span %s to descend from %s", span.name, tt.name)
+				}
+			}
+		})
+	}
+}
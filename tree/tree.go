@@ -0,0 +1,430 @@
+// Package tree models a virtual directory hierarchy on top of flat menmos blobs.
+//
+// Every file and directory is a blob storing the ID of its parent directory and its name in metadata fields.
+// Directories are empty blobs carrying a directory tag. The root directory is virtual and has the ID RootID.
+package tree
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/payload"
+	"github.com/pkg/errors"
+)
+
+const (
+	// RootID is the parent ID of top-level entries.
+	RootID = "root"
+
+	// DefaultParentField is the field storing the ID of the parent directory of an entry.
+	DefaultParentField = "parent"
+
+	// DefaultNameField is the field storing the name of an entry.
+	DefaultNameField = "name"
+
+	// DefaultDirectoryTag is the tag identifying directory blobs.
+	DefaultDirectoryTag = "directory"
+
+	// DefaultCacheTTL is how long directory listings are cached by default.
+	DefaultCacheTTL = 30 * time.Second
+)
+
+// Options controls how the hierarchy is stored.
+type Options struct {
+	// ParentField, NameField and DirectoryTag default to DefaultParentField, DefaultNameField and DefaultDirectoryTag.
+	ParentField  string
+	NameField    string
+	DirectoryTag string
+
+	// Scope restricts the hierarchy to blobs matching an expression (e.g. a tenant tag).
+	// Blobs created by the tree are not tagged automatically, use Meta for that.
+	Scope payload.Expression
+
+	// Meta is the base metadata of the directories created by the tree.
+	Meta payload.BlobMeta
+
+	// CacheTTL is how long directory listings are cached. Zero means DefaultCacheTTL, a negative value disables caching.
+	CacheTTL time.Duration
+}
+
+// An Entry is a file or directory of the hierarchy.
+type Entry struct {
+	ID    string
+	Name  string
+	IsDir bool
+	Meta  payload.BlobMeta
+}
+
+type cachedListing struct {
+	entries []Entry
+	expires time.Time
+}
+
+// Tree is a virtual directory hierarchy. It is safe for concurrent use.
+type Tree struct {
+	client *menmos.Client
+	opts   Options
+
+	mu    sync.Mutex
+	cache map[string]cachedListing
+}
+
+// New returns a hierarchy over the blobs of a client.
+func New(client *menmos.Client, opts Options) *Tree {
+	if opts.ParentField == "" {
+		opts.ParentField = DefaultParentField
+	}
+	if opts.NameField == "" {
+		opts.NameField = DefaultNameField
+	}
+	if opts.DirectoryTag == "" {
+		opts.DirectoryTag = DefaultDirectoryTag
+	}
+	if opts.CacheTTL == 0 {
+		opts.CacheTTL = DefaultCacheTTL
+	}
+
+	return &Tree{client: client, opts: opts, cache: make(map[string]cachedListing)}
+}
+
+// Splits a slash-separated absolute path into its components.
+func splitPath(p string) []string {
+	cleaned := path.Clean("/" + p)
+	if cleaned == "/" {
+		return nil
+	}
+	return strings.Split(strings.TrimPrefix(cleaned, "/"), "/")
+}
+
+func pathError(op string, p string, err error) error {
+	return &fs.PathError{Op: op, Path: p, Err: err}
+}
+
+func (t *Tree) isDirectory(meta payload.BlobMeta) bool {
+	for _, tag := range meta.Tags {
+		if tag == t.opts.DirectoryTag {
+			return true
+		}
+	}
+	return false
+}
+
+// Invalidate drops all cached directory listings.
+func (t *Tree) Invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cache = make(map[string]cachedListing)
+}
+
+func (t *Tree) invalidate(dirIDs ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, dirID := range dirIDs {
+		delete(t.cache, dirID)
+	}
+}
+
+// Lists the entries of a directory by ID, sorted by name.
+func (t *Tree) list(ctx context.Context, dirID string) ([]Entry, error) {
+	if t.opts.CacheTTL > 0 {
+		t.mu.Lock()
+		cached, ok := t.cache[dirID]
+		t.mu.Unlock()
+
+		if ok && time.Now().Before(cached.expires) {
+			return cached.entries, nil
+		}
+	}
+
+	hits, err := t.client.QueryAll(ctx, t.opts.Scope.AndKeyValue(t.opts.ParentField, dirID))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(hits))
+	for _, hit := range hits {
+		name, ok := hit.Metadata.Fields[t.opts.NameField]
+		if !ok || name == "" {
+			continue
+		}
+		entries = append(entries, Entry{ID: hit.ID, Name: name, IsDir: t.isDirectory(hit.Metadata), Meta: hit.Metadata})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	if t.opts.CacheTTL > 0 {
+		t.mu.Lock()
+		t.cache[dirID] = cachedListing{entries: entries, expires: time.Now().Add(t.opts.CacheTTL)}
+		t.mu.Unlock()
+	}
+
+	return entries, nil
+}
+
+func (t *Tree) child(ctx context.Context, dirID string, name string) (*Entry, error) {
+	entries, err := t.list(ctx, dirID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		if entries[i].Name == name {
+			return &entries[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func rootEntry() Entry {
+	return Entry{ID: RootID, Name: "/", IsDir: true, Meta: payload.NewBlobMeta()}
+}
+
+// Stat returns the entry at a path.
+func (t *Tree) Stat(ctx context.Context, p string) (Entry, error) {
+	parts := splitPath(p)
+
+	current := rootEntry()
+	for _, part := range parts {
+		if !current.IsDir {
+			return Entry{}, pathError("stat", p, fs.ErrNotExist)
+		}
+
+		next, err := t.child(ctx, current.ID, part)
+		if err != nil {
+			return Entry{}, pathError("stat", p, err)
+		}
+		if next == nil {
+			return Entry{}, pathError("stat", p, fs.ErrNotExist)
+		}
+		current = *next
+	}
+
+	return current, nil
+}
+
+// Resolve returns the blob ID of the entry at a path.
+func (t *Tree) Resolve(ctx context.Context, p string) (string, error) {
+	entry, err := t.Stat(ctx, p)
+	if err != nil {
+		return "", err
+	}
+	return entry.ID, nil
+}
+
+// ListDir returns the entries of a directory, sorted by name.
+func (t *Tree) ListDir(ctx context.Context, p string) ([]Entry, error) {
+	dir, err := t.Stat(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if !dir.IsDir {
+		return nil, pathError("listdir", p, errors.New("not a directory"))
+	}
+
+	entries, err := t.list(ctx, dir.ID)
+	if err != nil {
+		return nil, pathError("listdir", p, err)
+	}
+
+	return append([]Entry{}, entries...), nil
+}
+
+// Returns the parent directory of a path and the name of the entry in it, ensuring the name is free.
+func (t *Tree) prepareCreate(ctx context.Context, op string, p string) (Entry, string, error) {
+	parts := splitPath(p)
+	if len(parts) == 0 {
+		return Entry{}, "", pathError(op, p, fs.ErrExist)
+	}
+
+	parentPath := "/" + strings.Join(parts[:len(parts)-1], "/")
+	name := parts[len(parts)-1]
+
+	parent, err := t.Stat(ctx, parentPath)
+	if err != nil {
+		return Entry{}, "", err
+	}
+	if !parent.IsDir {
+		return Entry{}, "", pathError(op, p, errors.New("parent is not a directory"))
+	}
+
+	existing, err := t.child(ctx, parent.ID, name)
+	if err != nil {
+		return Entry{}, "", pathError(op, p, err)
+	}
+	if existing != nil {
+		return Entry{}, "", pathError(op, p, fs.ErrExist)
+	}
+
+	return parent, name, nil
+}
+
+func (t *Tree) entryMeta(base payload.BlobMeta, parentID string, name string) payload.BlobMeta {
+	meta := menmos.CopyMeta(base)
+	meta.Fields[t.opts.ParentField] = parentID
+	meta.Fields[t.opts.NameField] = name
+	return meta
+}
+
+// Mkdir creates a directory. Its parent must exist.
+func (t *Tree) Mkdir(ctx context.Context, p string) (string, error) {
+	parent, name, err := t.prepareCreate(ctx, "mkdir", p)
+	if err != nil {
+		return "", err
+	}
+
+	meta := t.entryMeta(t.opts.Meta, parent.ID, name)
+	meta.Tags = append(meta.Tags, t.opts.DirectoryTag)
+
+	id, err := t.client.CreateBlobContext(ctx, nil, meta, 0)
+	if err != nil {
+		return "", pathError("mkdir", p, err)
+	}
+
+	t.invalidate(parent.ID)
+	return id, nil
+}
+
+// MkdirAll creates a directory along with any missing parents, and returns its ID.
+func (t *Tree) MkdirAll(ctx context.Context, p string) (string, error) {
+	parts := splitPath(p)
+
+	current := rootEntry()
+	for i, part := range parts {
+		next, err := t.child(ctx, current.ID, part)
+		if err != nil {
+			return "", pathError("mkdir", p, err)
+		}
+
+		if next == nil {
+			id, err := t.Mkdir(ctx, "/"+strings.Join(parts[:i+1], "/"))
+			if err != nil {
+				return "", err
+			}
+			current = Entry{ID: id, Name: part, IsDir: true}
+			continue
+		}
+
+		if !next.IsDir {
+			return "", pathError("mkdir", p, errors.New("not a directory"))
+		}
+		current = *next
+	}
+
+	return current.ID, nil
+}
+
+// Create creates a file with the provided body. Its parent directory must exist.
+func (t *Tree) Create(ctx context.Context, p string, body io.ReadCloser, size uint64, meta payload.BlobMeta) (string, error) {
+	parent, name, err := t.prepareCreate(ctx, "create", p)
+	if err != nil {
+		if body != nil {
+			body.Close()
+		}
+		return "", err
+	}
+
+	id, err := t.client.CreateBlobContext(ctx, body, t.entryMeta(meta, parent.ID, name), size)
+	if err != nil {
+		return "", pathError("create", p, err)
+	}
+
+	t.invalidate(parent.ID)
+	return id, nil
+}
+
+// Move moves or renames an entry. The destination must not exist, and a directory cannot be moved into itself.
+func (t *Tree) Move(ctx context.Context, src string, dst string) error {
+	srcParts := splitPath(src)
+	dstParts := splitPath(dst)
+	if len(srcParts) == 0 {
+		return pathError("move", src, errors.New("cannot move the root directory"))
+	}
+
+	srcClean := "/" + strings.Join(srcParts, "/")
+	dstClean := "/" + strings.Join(dstParts, "/")
+	if dstClean == srcClean || strings.HasPrefix(dstClean, srcClean+"/") {
+		return pathError("move", dst, errors.New("cannot move an entry into itself"))
+	}
+
+	entry, err := t.Stat(ctx, src)
+	if err != nil {
+		return err
+	}
+
+	srcParent, err := t.Stat(ctx, path.Dir(srcClean))
+	if err != nil {
+		return err
+	}
+
+	dstParent, name, err := t.prepareCreate(ctx, "move", dst)
+	if err != nil {
+		return err
+	}
+
+	meta := t.entryMeta(entry.Meta, dstParent.ID, name)
+	if err := t.client.UpdateMetaContext(ctx, entry.ID, meta); err != nil {
+		return pathError("move", src, err)
+	}
+
+	t.invalidate(srcParent.ID, dstParent.ID)
+	return nil
+}
+
+func (t *Tree) removeRecursive(ctx context.Context, entry Entry) error {
+	if entry.IsDir {
+		// A cached listing may miss entries created since, which would be left orphaned.
+		t.invalidate(entry.ID)
+		children, err := t.list(ctx, entry.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, child := range children {
+			if err := t.removeRecursive(ctx, child); err != nil {
+				return err
+			}
+		}
+		t.invalidate(entry.ID)
+	}
+
+	if entry.ID == RootID {
+		return nil
+	}
+
+	return t.client.DeleteContext(ctx, entry.ID)
+}
+
+// RemoveAll removes an entry and, for directories, everything it contains.
+// Removing the root directory removes all of its contents. Removing a missing path is not an error.
+func (t *Tree) RemoveAll(ctx context.Context, p string) error {
+	entry, err := t.Stat(ctx, p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	parts := splitPath(p)
+	parent := rootEntry()
+	if len(parts) > 0 {
+		if parent, err = t.Stat(ctx, "/"+strings.Join(parts[:len(parts)-1], "/")); err != nil {
+			return err
+		}
+	}
+
+	err = t.removeRecursive(ctx, entry)
+	t.invalidate(parent.ID)
+	if err != nil {
+		return pathError("removeall", p, err)
+	}
+
+	return nil
+}
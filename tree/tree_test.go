@@ -0,0 +1,146 @@
+package tree_test
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/internal/menmostest"
+	"github.com/menmos/menmos-go/payload"
+	"github.com/menmos/menmos-go/tree"
+	"github.com/pkg/errors"
+)
+
+func entryNames(entries []tree.Entry) []string {
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	return names
+}
+
+func expectNames(t *testing.T, hierarchy *tree.Tree, dirPath string, expected ...string) {
+	t.Helper()
+
+	entries, err := hierarchy.ListDir(context.Background(), dirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := entryNames(entries)
+	if len(names) != len(expected) {
+		t.Fatalf("%s: expected %v, got %v", dirPath, expected, names)
+	}
+	for i := range names {
+		if names[i] != expected[i] {
+			t.Fatalf("%s: expected %v, got %v", dirPath, expected, names)
+		}
+	}
+}
+
+func Test_Tree(t *testing.T) {
+	server := menmostest.NewServer()
+	defer server.Close()
+
+	client, err := menmos.New(server.URL, "admin", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	hierarchy := tree.New(client, tree.Options{})
+
+	if _, err := hierarchy.MkdirAll(ctx, "/a/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := hierarchy.Mkdir(ctx, "/a/b"); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("expected ErrExist, got %v", err)
+	}
+
+	if _, err := hierarchy.Mkdir(ctx, "/missing/dir"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist, got %v", err)
+	}
+
+	fileID, err := hierarchy.Create(ctx, "/a/b/c.txt", ioutil.NopCloser(bytes.NewReader([]byte("hello"))), 5, payload.NewBlobMeta())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, err := hierarchy.Resolve(ctx, "/a/b/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved != fileID {
+		t.Errorf("expected %s, got %s", fileID, resolved)
+	}
+
+	expectNames(t, hierarchy, "/", "a")
+	expectNames(t, hierarchy, "/a", "b")
+	expectNames(t, hierarchy, "/a/b", "c.txt")
+
+	if err := hierarchy.Move(ctx, "/a", "/a/b/a"); err == nil {
+		t.Fatal("expected moving a directory into itself to fail")
+	}
+
+	if err := hierarchy.Move(ctx, "/a/b", "/moved"); err != nil {
+		t.Fatal(err)
+	}
+
+	expectNames(t, hierarchy, "/", "a", "moved")
+	expectNames(t, hierarchy, "/a")
+	expectNames(t, hierarchy, "/moved", "c.txt")
+
+	if resolved, err := hierarchy.Resolve(ctx, "/moved/c.txt"); err != nil || resolved != fileID {
+		t.Fatalf("expected the file to follow its directory, got %s (%v)", resolved, err)
+	}
+
+	if err := hierarchy.RemoveAll(ctx, "/moved"); err != nil {
+		t.Fatal(err)
+	}
+
+	expectNames(t, hierarchy, "/", "a")
+	if server.BlobCount() != 1 {
+		t.Errorf("expected only /a to remain, got %d blobs", server.BlobCount())
+	}
+}
+
+func Test_RemoveAllIgnoresCachedListings(t *testing.T) {
+	server := menmostest.NewServer()
+	defer server.Close()
+
+	client, err := menmos.New(server.URL, "admin", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	hierarchy := tree.New(client, tree.Options{CacheTTL: time.Hour})
+	other := tree.New(client, tree.Options{CacheTTL: time.Hour})
+
+	if _, err := hierarchy.MkdirAll(ctx, "/a/b"); err != nil {
+		t.Fatal(err)
+	}
+	expectNames(t, hierarchy, "/a", "b")
+	expectNames(t, hierarchy, "/a/b")
+
+	// Created behind the back of the first tree, whose listings of /a and /a/b are still fresh.
+	if _, err := other.Create(ctx, "/a/b/c.txt", ioutil.NopCloser(bytes.NewReader([]byte("hello"))), 5, payload.NewBlobMeta()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Mkdir(ctx, "/a/d"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hierarchy.RemoveAll(ctx, "/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if server.BlobCount() != 0 {
+		t.Errorf("expected no blobs to remain, got %d", server.BlobCount())
+	}
+}
@@ -0,0 +1,144 @@
+package menmos
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/menmos/menmos-go/payload"
+	"github.com/pkg/errors"
+)
+
+// Writes data at an offset of a blob with a ranged PUT, without any metadata check.
+//
+// The body of a ranged write is exactly the bytes of the range, so unlike uploads no X-Blob-Size header is sent:
+// the storage node grows the blob when the range ends past its current size.
+func (c *Client) writeRange(ctx context.Context, blobID string, offset int64, data []byte) error {
+	if offset < 0 {
+		return fmt.Errorf("invalid write offset: %d", offset)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	path := fmt.Sprintf("/blob/%s", blobID)
+	req, err := c.makeRequest(ctx, "PUT", path, nil)
+	if err != nil {
+		return err
+	}
+
+	redirectLocation, err := c.doWithRedirect(req)
+	if err != nil {
+		return err
+	}
+
+	req, err = c.makeRequest(ctx, "PUT", path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.URL = redirectLocation
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+int64(len(data))-1))
+	c.metrics.AddBytesUploaded(int64(len(data)))
+
+	var response payload.MessageResponse
+	return c.doJSONRequest(req, &response)
+}
+
+// Checks that a blob can be written in place, returning its metadata.
+func (c *Client) checkWritable(ctx context.Context, blobID string) (payload.BlobMeta, error) {
+	if err := c.RequireCapability(ctx, CapabilityRangedWrites); err != nil {
+		return payload.BlobMeta{}, err
+	}
+
+	meta, err := c.GetMetadataContext(ctx, blobID)
+	if err != nil {
+		return payload.BlobMeta{}, err
+	}
+
+	if codec, ok := meta.Fields[CodecField]; ok {
+		return payload.BlobMeta{}, fmt.Errorf("blob '%s' is encoded with '%s' and can't be written in place", blobID, codec)
+	}
+
+	return meta, nil
+}
+
+// Removes the checksum of a blob whose content was modified in place, since it no longer matches.
+func (c *Client) dropChecksum(ctx context.Context, blobID string, meta payload.BlobMeta) error {
+	if _, ok := meta.Fields[ChecksumField]; !ok {
+		return nil
+	}
+
+	meta = CopyMeta(meta)
+	delete(meta.Fields, ChecksumField)
+	if err := c.UpdateMetaContext(ctx, blobID, meta); err != nil {
+		return errors.Wrap(err, "failed to remove stale checksum")
+	}
+	return nil
+}
+
+// WriteAt writes the content of r at an offset of an existing blob, leaving the rest of the blob untouched.
+// Writing past the end of the blob grows it. Returns the number of bytes written.
+//
+// The content of r is read in memory before being sent. Blobs encoded with a codec can't be written in place,
+// and the recorded checksum of a blob, if any, is removed since it no longer matches its content.
+func (c *Client) WriteAt(ctx context.Context, blobID string, offset int64, r io.Reader) (n int64, err error) {
+	ctx, finish := c.startOperation(ctx, OpWriteAt)
+	defer finish(&err)
+
+	meta, err := c.checkWritable(ctx, blobID)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read data to write")
+	}
+
+	if err := c.writeRange(ctx, blobID, offset, data); err != nil {
+		return 0, err
+	}
+
+	if err := c.dropChecksum(ctx, blobID, meta); err != nil {
+		return int64(len(data)), err
+	}
+
+	return int64(len(data)), nil
+}
+
+// BlobWriterAt writes to a blob in place. It implements io.WriterAt.
+type BlobWriterAt struct {
+	ctx    context.Context
+	client *Client
+	blobID string
+}
+
+var _ io.WriterAt = (*BlobWriterAt)(nil)
+
+// WriterAt returns an io.WriterAt writing to an existing blob, with the same restrictions as WriteAt.
+// The blob is checked once, when the writer is created.
+func (c *Client) WriterAt(ctx context.Context, blobID string) (*BlobWriterAt, error) {
+	meta, err := c.checkWritable(ctx, blobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.dropChecksum(ctx, blobID, meta); err != nil {
+		return nil, err
+	}
+
+	return &BlobWriterAt{ctx: ctx, client: c, blobID: blobID}, nil
+}
+
+// WriteAt writes p at offset off of the blob.
+func (w *BlobWriterAt) WriteAt(p []byte, off int64) (n int, err error) {
+	ctx, finish := w.client.startOperation(w.ctx, OpWriteAt)
+	defer finish(&err)
+
+	if err := w.client.writeRange(ctx, w.blobID, off, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,177 @@
+package menmos_test
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	menmos "github.com/menmos/menmos-go"
+	"github.com/menmos/menmos-go/payload"
+	"github.com/pkg/errors"
+)
+
+func Test_WriteAt(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	meta := payload.NewBlobMeta()
+	meta.Fields[menmos.ChecksumField] = "sha256:stale"
+	id := server.Put([]byte("hello world"), meta)
+
+	n, err := client.WriteAt(ctx, id, 6, strings.NewReader("there"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Errorf("expected 5 bytes written, got %d", n)
+	}
+
+	if _, err := client.WriteAt(ctx, id, 11, strings.NewReader("!!")); err != nil {
+		t.Fatal(err)
+	}
+
+	blob, _ := server.Blob(id)
+	if string(blob.Data) != "hello there!!" {
+		t.Errorf("unexpected content %q", blob.Data)
+	}
+	if _, ok := blob.Meta.Fields[menmos.ChecksumField]; ok {
+		t.Error("stale checksum wasn't removed")
+	}
+
+	writer, err := client.WriterAt(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.WriteAt([]byte("HELLO"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if blob, _ := server.Blob(id); string(blob.Data) != "HELLO there!!" {
+		t.Errorf("unexpected content %q", blob.Data)
+	}
+
+	if _, err := client.WriteAt(ctx, id, -1, strings.NewReader("x")); err == nil {
+		t.Error("expected a negative offset to be rejected")
+	}
+}
+
+func Test_WriteAtRestrictions(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	compressed, err := menmos.New(server.URL, "admin", "password", menmos.WithCompression(menmos.Gzip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := compressed.CreateBlob(ioutil.NopCloser(strings.NewReader("hello")), payload.NewBlobMeta(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.WriteAt(ctx, id, 0, strings.NewReader("x")); err == nil {
+		t.Error("expected writing an encoded blob to fail")
+	}
+
+	server.SetVersion("0.1.0")
+	old, err := menmos.New(server.URL, "admin", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.WriteAt(ctx, server.Put([]byte("x"), payload.NewBlobMeta()), 0, strings.NewReader("y")); errors.Cause(err) != menmos.ErrServerTooOld {
+		t.Errorf("expected ErrServerTooOld, got %v", err)
+	}
+}
+
+func Test_Appender(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	id := server.Put([]byte("log:"), payload.NewBlobMeta())
+
+	appender, err := client.OpenAppender(ctx, id, menmos.WithAppendChunkSize(4), menmos.WithFsyncOnClose())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := appender.Write([]byte("abcdef")); err != nil {
+		t.Fatal(err)
+	}
+	if blob, _ := server.Blob(id); string(blob.Data) != "log:abcd" {
+		t.Errorf("expected only full chunks to be written, got %q", blob.Data)
+	}
+	if appender.Size() != 10 {
+		t.Errorf("expected size 10, got %d", appender.Size())
+	}
+
+	if _, err := appender.Write([]byte("g")); err != nil {
+		t.Fatal(err)
+	}
+	if err := appender.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	blob, _ := server.Blob(id)
+	if string(blob.Data) != "log:abcdefg" {
+		t.Errorf("unexpected content %q", blob.Data)
+	}
+	if blob.Fsyncs != 1 {
+		t.Errorf("expected 1 fsync, got %d", blob.Fsyncs)
+	}
+
+	if _, err := appender.Write([]byte("x")); err != menmos.ErrAppenderClosed {
+		t.Errorf("expected ErrAppenderClosed, got %v", err)
+	}
+
+	if _, err := client.OpenAppender(ctx, id, menmos.WithAppendChunkSize(0)); err == nil {
+		t.Error("expected an invalid chunk size to be rejected")
+	}
+}
+
+func Test_BlobWriter(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	meta := payload.NewBlobMeta()
+	meta.Tags = append(meta.Tags, "streamed")
+
+	writer, err := client.NewBlobWriter(ctx, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := writer.Write([]byte("chunk;")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if writer.ID() != "" {
+		t.Error("expected no ID before close")
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	blob, ok := server.Blob(writer.ID())
+	if !ok {
+		t.Fatalf("blob '%s' wasn't created", writer.ID())
+	}
+	if string(blob.Data) != "chunk;chunk;chunk;" {
+		t.Errorf("unexpected content %q", blob.Data)
+	}
+	if len(blob.Meta.Tags) != 1 || blob.Meta.Tags[0] != "streamed" {
+		t.Errorf("unexpected tags %v", blob.Meta.Tags)
+	}
+
+	if _, err := writer.Write([]byte("x")); err != menmos.ErrBlobWriterClosed {
+		t.Errorf("expected ErrBlobWriterClosed, got %v", err)
+	}
+
+	aborted, err := client.NewBlobWriter(ctx, payload.NewBlobMeta())
+	if err != nil {
+		t.Fatal(err)
+	}
+	aborted.Write([]byte("discarded"))
+	aborted.Abort()
+	if err := aborted.Close(); err != nil || aborted.ID() != "" {
+		t.Errorf("expected an aborted writer to create nothing, got '%s' (%v)", aborted.ID(), err)
+	}
+}